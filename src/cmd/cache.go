@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"promptorium/internal/pkg/cachepkg"
+
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect and clear the promptorium cache",
+	Long:  `Inspects and clears the cache of git repository states (enabled with the git.cache option) and of last known context values.`,
+}
+
+var cacheShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the cached git repositories",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runCacheShowCmd()
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Clear the cache",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runCacheClearCmd()
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheShowCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
+
+func runCacheShowCmd() {
+	output, err := cachepkg.ShowCache()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "promptorium:", err)
+		os.Exit(1)
+	}
+	fmt.Print(output)
+}
+
+func runCacheClearCmd() {
+	err := cachepkg.ClearCache()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "promptorium:", err)
+		os.Exit(1)
+	}
+	fmt.Println("Cache cleared")
+}
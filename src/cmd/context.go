@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"promptorium/internal/pkg/confpkg/context"
+	"promptorium/internal/pkg/contextpkg"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var contextCmd = &cobra.Command{
+	Use:   "context",
+	Short: "Inspect the context the prompt is rendered with",
+}
+
+var contextDumpCmd = &cobra.Command{
+	Use:   "dump",
+	Short: "Print the resolved context as JSON",
+	Long: `Prints the resolved context (git state, cwd, OS, shell, terminal width, exit code...) as JSON.
+	The output can be passed to 'promptorium prompt --context-file' to render the same prompt on another machine.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runContextDumpCmd(cmd.Flags())
+	},
+}
+
+func init() {
+	contextDumpCmd.Flags().StringP("shell", "s", "", "Shell for which to format the prompt (bash, zsh, fish, pwsh, nu)")
+	contextDumpCmd.Flags().IntP("exit-code", "e", 0, "Exit code of the previous command")
+	contextDumpCmd.Flags().String("pipestatus", "", "Exit codes of the commands of the previous pipeline, separated by spaces")
+	contextDumpCmd.Flags().String("start-time", "", "Time at which the previous command started, in seconds since the epoch (e.g. $EPOCHREALTIME)")
+	contextDumpCmd.Flags().Int("cmd-duration", 0, "Duration of the previous command in milliseconds, measured by the shell (e.g. $CMD_DURATION of fish)")
+	contextDumpCmd.Flags().Int("jobs", 0, "Number of background and stopped jobs of the shell")
+	contextDumpCmd.Flags().StringP("output", "o", "", "Write the context to a file instead of stdout")
+	contextCmd.AddCommand(contextDumpCmd)
+	rootCmd.AddCommand(contextCmd)
+}
+
+func runContextDumpCmd(pFlags *pflag.FlagSet) {
+	var shell string
+	var exitCode int
+	var pipeStatus string
+	var startTime string
+	var cmdDuration int
+	var jobs int
+	var outputPath string
+
+	pFlags.VisitAll(func(flag *pflag.Flag) {
+		if flag.Name == "shell" {
+			shell = flag.Value.String()
+		}
+		if flag.Name == "exit-code" {
+			exitCode, _ = strconv.Atoi(flag.Value.String())
+		}
+		if flag.Name == "pipestatus" {
+			pipeStatus = flag.Value.String()
+		}
+		if flag.Name == "jobs" {
+			jobs, _ = strconv.Atoi(flag.Value.String())
+		}
+		if flag.Name == "cmd-duration" {
+			cmdDuration, _ = strconv.Atoi(flag.Value.String())
+		}
+		if flag.Name == "start-time" {
+			startTime = flag.Value.String()
+		}
+		if flag.Name == "output" {
+			outputPath = flag.Value.String()
+		}
+	})
+
+	commandStart, err := context.ParseCommandStart(startTime)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "promptorium: Invalid start time", startTime, ":", err)
+		os.Exit(1)
+	}
+	pipeStatusCodes, err := context.ParsePipeStatus(pipeStatus)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "promptorium: Invalid pipestatus", pipeStatus, ":", err)
+		os.Exit(1)
+	}
+	values := context.ShellValues{Shell: shell, ExitCode: exitCode, PipeStatus: pipeStatusCodes, CommandStart: commandStart, CmdDuration: time.Duration(cmdDuration) * time.Millisecond, Jobs: jobs}
+	output, err := contextpkg.DumpContext(values, outputPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "promptorium:", err)
+		os.Exit(1)
+	}
+	fmt.Print(output)
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"promptorium/internal/pkg/daemonpkg"
+
+	"github.com/spf13/cobra"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Manage the promptorium daemon",
+	Long: `Manages the promptorium daemon, which watches the recently visited git repositories and keeps their state in memory.
+	When the daemon is running, 'promptorium prompt' queries it instead of running git for every prompt.`,
+}
+
+var daemonStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start the daemon in the background",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		foreground, _ := cmd.Flags().GetBool("foreground")
+		runDaemonStartCmd(foreground, Version)
+	},
+}
+
+var daemonStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the daemon",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		printDaemonOutput(daemonpkg.StopDaemon())
+	},
+}
+
+var daemonStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the status of the daemon and the watched repositories",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		printDaemonOutput(daemonpkg.GetDaemonStatus())
+	},
+}
+
+func init() {
+	daemonStartCmd.Flags().BoolP("foreground", "f", false, "Run the daemon in the foreground (e.g. from a systemd user service)")
+	daemonCmd.AddCommand(daemonStartCmd)
+	daemonCmd.AddCommand(daemonStopCmd)
+	daemonCmd.AddCommand(daemonStatusCmd)
+	rootCmd.AddCommand(daemonCmd)
+}
+
+func runDaemonStartCmd(foreground bool, version string) {
+	if !foreground {
+		printDaemonOutput(daemonpkg.StartDaemon())
+		return
+	}
+	err := daemonpkg.RunDaemon(version)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "promptorium:", err)
+		os.Exit(1)
+	}
+}
+
+func printDaemonOutput(output string, err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "promptorium:", err)
+		os.Exit(1)
+	}
+	fmt.Print(output)
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"promptorium/internal/pkg/modulespkg"
+
+	"github.com/spf13/cobra"
+)
+
+var modulesCmd = &cobra.Command{
+	Use:   "modules",
+	Short: "List and describe the available modules",
+	Long:  `Lists the modules that can be used in components of type "module", and shows the details of each module.`,
+}
+
+var modulesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the available modules",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Print(modulespkg.ListModules())
+	},
+}
+
+var modulesDescribeCmd = &cobra.Command{
+	Use:   "describe <module>",
+	Short: "Describe a module",
+	Long:  `Prints the description, options, context providers and example output of a module.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runModulesDescribeCmd(args[0])
+	},
+}
+
+func init() {
+	modulesCmd.AddCommand(modulesListCmd)
+	modulesCmd.AddCommand(modulesDescribeCmd)
+	rootCmd.AddCommand(modulesCmd)
+}
+
+func runModulesDescribeCmd(name string) {
+	description, err := modulespkg.DescribeModule(name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "promptorium:", err)
+		os.Exit(1)
+	}
+	fmt.Print(description)
+}
@@ -2,10 +2,13 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"promptorium/internal/log"
+	"promptorium/internal/pkg/confpkg/context"
 	"promptorium/internal/pkg/promptpkg"
 	"strconv"
+	"time"
 
-	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 )
@@ -22,8 +25,15 @@ var promptCmd = &cobra.Command{
 
 func init() {
 	promptCmd.Flags().StringP("config-file", "c", "", "Path to the config file")
-	promptCmd.Flags().StringP("shell", "s", "", "Shell for which to format the prompt (bash, zsh)")
+	promptCmd.Flags().StringP("shell", "s", "", "Shell for which to format the prompt (bash, zsh, fish, pwsh, nu)")
 	promptCmd.Flags().IntP("exit-code", "e", 0, "Exit code of the previous command")
+	promptCmd.Flags().String("pipestatus", "", "Exit codes of the commands of the previous pipeline, separated by spaces")
+	promptCmd.Flags().String("start-time", "", "Time at which the previous command started, in seconds since the epoch (e.g. $EPOCHREALTIME)")
+	promptCmd.Flags().Int("cmd-duration", 0, "Duration of the previous command in milliseconds, measured by the shell (e.g. $CMD_DURATION of fish)")
+	promptCmd.Flags().Int("jobs", 0, "Number of background and stopped jobs of the shell")
+	promptCmd.Flags().String("part", "", "Only render one side of the prompt (left, right), or both separated by the \\x1f character, for the shells which draw the right prompt themselves")
+	promptCmd.Flags().Bool("transient", false, "Render the transient_prompt layout, which replaces the prompt once a command is accepted")
+	promptCmd.Flags().String("context-file", "", "Render from a context snapshot written by 'promptorium context dump' instead of probing the system")
 	rootCmd.AddCommand(promptCmd)
 }
 
@@ -32,6 +42,13 @@ func runPromptCmd(pFlags *pflag.FlagSet, version string) {
 	var configPath string
 	var shell string
 	var exitCode int
+	var pipeStatus string
+	var startTime string
+	var cmdDuration int
+	var jobs int
+	var contextFile string
+	var part string
+	var transient bool
 
 	pFlags.VisitAll(func(flag *pflag.Flag) {
 		if flag.Name == "config-file" {
@@ -43,8 +60,43 @@ func runPromptCmd(pFlags *pflag.FlagSet, version string) {
 		if flag.Name == "exit-code" {
 			exitCode, _ = strconv.Atoi(flag.Value.String())
 		}
+		if flag.Name == "pipestatus" {
+			pipeStatus = flag.Value.String()
+		}
+		if flag.Name == "jobs" {
+			jobs, _ = strconv.Atoi(flag.Value.String())
+		}
+		if flag.Name == "cmd-duration" {
+			cmdDuration, _ = strconv.Atoi(flag.Value.String())
+		}
+		if flag.Name == "start-time" {
+			startTime = flag.Value.String()
+		}
+		if flag.Name == "part" {
+			part = flag.Value.String()
+		}
+		if flag.Name == "transient" {
+			transient = flag.Value.String() == "true"
+		}
+		if flag.Name == "context-file" {
+			contextFile = flag.Value.String()
+		}
 	})
 	log.Debug().Msgf("Version: %s", version)
 
-	fmt.Print(promptpkg.GetPrompt(configPath, shell, exitCode, version))
+	commandStart, err := context.ParseCommandStart(startTime)
+	if err != nil {
+		log.Debug().Msgf("Invalid start time %s: %s", startTime, err)
+	}
+	pipeStatusCodes, err := context.ParsePipeStatus(pipeStatus)
+	if err != nil {
+		log.Debug().Msgf("Invalid pipestatus %s: %s", pipeStatus, err)
+	}
+	values := context.ShellValues{Shell: shell, ExitCode: exitCode, PipeStatus: pipeStatusCodes, CommandStart: commandStart, CmdDuration: time.Duration(cmdDuration) * time.Millisecond, Jobs: jobs}
+	align, ok := promptpkg.ParsePart(part)
+	if part != "" && !ok {
+		fmt.Fprintln(os.Stderr, "promptorium: Invalid part", part, ": must be left, right or both")
+		os.Exit(1)
+	}
+	fmt.Print(promptpkg.GetPrompt(configPath, values, contextFile, align, transient, version))
 }
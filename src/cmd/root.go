@@ -3,10 +3,10 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"promptorium/internal/log"
 	"time"
 
 	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 )
 
@@ -19,7 +19,7 @@ var shellCmd = &cobra.Command{
 
 func init() {
 	shellCmd.Flags().StringP("config-file", "c", "", "Path to the config file")
-	shellCmd.Flags().StringP("shell", "s", "", "Shell for which to format the prompt (bash, zsh)")
+	shellCmd.Flags().StringP("shell", "s", "", "Shell for which to format the prompt (bash, zsh, fish, pwsh, nu)")
 	rootCmd.AddCommand(shellCmd)
 }
 
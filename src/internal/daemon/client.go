@@ -0,0 +1,87 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"promptorium/internal/log"
+	"promptorium/internal/pkg/confpkg/context/gitcontext"
+	"promptorium/internal/pkg/confpkg/context/oscontext"
+	"time"
+)
+
+var DIAL_TIMEOUT = 50 * time.Millisecond
+var QUERY_TIMEOUT = 2 * time.Second
+
+// Query sends a request to the daemon and returns its response.
+// It fails quickly when the daemon is not running.
+func Query(request Request) (Response, error) {
+	response := Response{}
+	socketPath := GetSocketPath()
+	err := CheckSocketDir(socketPath)
+	if err != nil {
+		return response, err
+	}
+	conn, err := net.DialTimeout("unix", socketPath, DIAL_TIMEOUT)
+	if err != nil {
+		return response, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(QUERY_TIMEOUT))
+
+	err = json.NewEncoder(conn).Encode(request)
+	if err != nil {
+		return response, err
+	}
+	err = json.NewDecoder(conn).Decode(&response)
+	if err != nil {
+		return response, err
+	}
+	if response.Error != "" {
+		return response, errors.New(response.Error)
+	}
+	return response, nil
+}
+
+// GetGitContext returns the git context of the repository containing cwd, as known by the daemon
+func GetGitContext(cwd string) (gitcontext.GitContext, bool) {
+	response, err := Query(Request{Command: CommandGit, CWD: cwd})
+	if err != nil || response.GitContext == nil {
+		log.Trace().Msgf("Could not get git context from the daemon: %v", err)
+		return gitcontext.GitContext{}, false
+	}
+	log.Trace().Msg("Using git context from the daemon")
+	result := *response.GitContext
+	gitRoot := response.GitRoot
+	result.GitRoot = func() string { return gitRoot }
+	return result, true
+}
+
+// GetOS returns the OS, as known by the daemon
+func GetOS() (oscontext.OS, bool) {
+	response, err := Query(Request{Command: CommandOS})
+	if err != nil || response.OS == nil {
+		log.Trace().Msgf("Could not get OS from the daemon: %v", err)
+		return oscontext.OS{}, false
+	}
+	log.Trace().Msg("Using OS from the daemon")
+	return *response.OS, true
+}
+
+// GetStatus returns the status of the daemon, or an error if it is not running
+func GetStatus() (Status, error) {
+	response, err := Query(Request{Command: CommandStatus})
+	if err != nil {
+		return Status{}, err
+	}
+	if response.Status == nil {
+		return Status{}, errors.New("invalid response from the daemon")
+	}
+	return *response.Status, nil
+}
+
+// Stop asks the daemon to exit
+func Stop() error {
+	_, err := Query(Request{Command: CommandStop})
+	return err
+}
@@ -0,0 +1,98 @@
+package daemon
+
+// The daemon keeps the git context of the recently visited repositories and the other slow providers warm,
+// and serves them to "promptorium prompt" over a unix socket.
+// Each connection carries a single request and a single response, both encoded as JSON.
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"promptorium/internal/pkg/confpkg/context/gitcontext"
+	"promptorium/internal/pkg/confpkg/context/oscontext"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+type Command string
+
+const (
+	CommandGit    Command = "git"
+	CommandOS     Command = "os"
+	CommandStatus Command = "status"
+	CommandStop   Command = "stop"
+)
+
+type Request struct {
+	Command Command `json:"command"`
+	CWD     string  `json:"cwd,omitempty"`
+}
+
+type Response struct {
+	Error      string                 `json:"error,omitempty"`
+	GitContext *gitcontext.GitContext `json:"git,omitempty"`
+	GitRoot    string                 `json:"git_root,omitempty"`
+	OS         *oscontext.OS          `json:"os,omitempty"`
+	Status     *Status                `json:"status,omitempty"`
+}
+
+type Status struct {
+	PID          int                `json:"pid"`
+	Version      string             `json:"version"`
+	StartedAt    time.Time          `json:"started_at"`
+	Repositories []RepositoryStatus `json:"repositories"`
+}
+
+type RepositoryStatus struct {
+	Root     string    `json:"root"`
+	Watches  int       `json:"watches"`
+	Watched  bool      `json:"watched"`
+	Cached   bool      `json:"cached"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// GetSocketPath returns the path of the daemon socket ($XDG_RUNTIME_DIR/promptorium.sock).
+// If XDG_RUNTIME_DIR is not set, the socket is created in a private directory of the temporary directory,
+// which is checked with CheckSocketDir before it is used.
+func GetSocketPath() string {
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		runtimeDir = filepath.Join(os.TempDir(), "promptorium-"+strconv.Itoa(os.Getuid()))
+	}
+	return filepath.Join(runtimeDir, "promptorium.sock")
+}
+
+// PrepareSocketDir creates the directory of the socket if needed, and checks it with CheckSocketDir
+func PrepareSocketDir(socketPath string) error {
+	err := os.MkdirAll(filepath.Dir(socketPath), 0700)
+	if err != nil {
+		return err
+	}
+	return CheckSocketDir(socketPath)
+}
+
+// CheckSocketDir returns an error unless the directory of the socket is a directory owned by the current user
+// and only accessible by them. Otherwise, another user could create the directory in the temporary directory
+// first and serve their own git context to the prompt.
+func CheckSocketDir(socketPath string) error {
+	dir := filepath.Dir(socketPath)
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("%s is a symlink", dir)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("%s is not owned by the current user", dir)
+	}
+	if info.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky) != 0700 {
+		return fmt.Errorf("%s must have the permissions 0700, not %o", dir, info.Mode().Perm())
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"promptorium/internal/log"
+	"promptorium/internal/pkg/confpkg/context/gitcontext"
+	"promptorium/internal/pkg/confpkg/context/oscontext"
+	"promptorium/internal/utils"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var MAX_REPOSITORIES = 16
+var REPOSITORY_IDLE_TIMEOUT = time.Hour
+
+type repository struct {
+	root     string
+	lastUsed time.Time
+	watches  map[int32]bool
+	watched  bool
+	removed  bool
+
+	// Incremented on every change, so that a git context computed during a change isn't kept
+	generation uint64
+	cached     bool
+	gitContext gitcontext.GitContext
+	gitRoot    string
+
+	// Serializes the git status runs of the repository
+	refreshMutex sync.Mutex
+}
+
+// Marks the cached git context as outdated. The mutex of the server must be held by the caller
+func (repo *repository) invalidate() {
+	repo.generation++
+	repo.cached = false
+}
+
+type server struct {
+	version   string
+	startedAt time.Time
+	listener  net.Listener
+	watcher   *watcher
+	os        utils.CachedData[oscontext.OS]
+	stop      chan struct{}
+
+	// Protects the repositories and the watches
+	mutex        sync.Mutex
+	repositories map[string]*repository
+}
+
+// Run starts the daemon in the foreground, and returns when it is stopped
+func Run(version string) error {
+	socketPath := GetSocketPath()
+	err := PrepareSocketDir(socketPath)
+	if err != nil {
+		return err
+	}
+	if _, err := GetStatus(); err == nil {
+		return errors.New("the daemon is already running")
+	}
+	// The socket of a daemon which didn't exit cleanly is left behind
+	os.Remove(socketPath)
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(socketPath)
+
+	server := &server{
+		version:      version,
+		startedAt:    time.Now(),
+		listener:     listener,
+		os:           utils.NewCachedData(oscontext.GetOS, "os"),
+		stop:         make(chan struct{}),
+		repositories: map[string]*repository{},
+	}
+	server.watcher, err = newWatcher(&server.mutex)
+	if err != nil {
+		listener.Close()
+		return fmt.Errorf("could not initialize inotify: %w", err)
+	}
+	defer server.watcher.close()
+
+	// The daemon only reads the repositories, so git must not take the index lock to refresh it
+	os.Setenv("GIT_OPTIONAL_LOCKS", "0")
+	server.os.Start()
+
+	go server.watcher.run()
+	go server.evictIdleRepositories()
+	go server.handleSignals()
+
+	log.Debug().Msgf("Listening on %s", socketPath)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-server.stop:
+				log.Debug().Msg("Daemon stopped")
+				return nil
+			default:
+				return err
+			}
+		}
+		go server.handleConnection(conn)
+	}
+}
+
+func (server *server) shutdown() {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	select {
+	case <-server.stop:
+	default:
+		close(server.stop)
+		server.listener.Close()
+	}
+}
+
+func (server *server) handleSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	<-signals
+	server.shutdown()
+}
+
+func (server *server) handleConnection(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(QUERY_TIMEOUT))
+
+	request := Request{}
+	response := Response{}
+	err := json.NewDecoder(conn).Decode(&request)
+	if err != nil {
+		response.Error = "invalid request: " + err.Error()
+	} else {
+		log.Trace().Msgf("Request: %s %s", request.Command, request.CWD)
+		response = server.handleRequest(request)
+	}
+
+	err = json.NewEncoder(conn).Encode(response)
+	if err != nil {
+		log.Debug().Msgf("Could not send response: %s", err)
+	}
+	if request.Command == CommandStop {
+		server.shutdown()
+	}
+}
+
+func (server *server) handleRequest(request Request) Response {
+	response := Response{}
+	switch request.Command {
+	case CommandGit:
+		gitContext, gitRoot := server.getGitContext(request.CWD)
+		response.GitContext = &gitContext
+		response.GitRoot = gitRoot
+	case CommandOS:
+		os := server.os.GetContent()
+		response.OS = &os
+	case CommandStatus:
+		status := server.getStatus()
+		response.Status = &status
+	case CommandStop:
+	default:
+		response.Error = fmt.Sprintf("unknown command %q", request.Command)
+	}
+	return response
+}
+
+// Returns the git context of the repository containing cwd, running git status only if the repository changed
+func (server *server) getGitContext(cwd string) (gitcontext.GitContext, string) {
+	root := gitcontext.FindGitRoot(cwd)
+	if root == "" {
+		// Bare repositories have no working tree to watch, their metadata is read directly
+		gitContext := gitcontext.ReadGitContext(cwd)
+		if !gitContext.IsGitRepo {
+			return gitContext, ""
+		}
+		return gitContext, gitContext.GitRoot()
+	}
+	repo := server.getRepository(root)
+
+	repo.refreshMutex.Lock()
+	defer repo.refreshMutex.Unlock()
+
+	server.mutex.Lock()
+	if repo.cached {
+		log.Trace().Msgf("Using cached git context for %s", root)
+		gitContext := repo.gitContext
+		server.mutex.Unlock()
+		return gitContext, root
+	}
+	generation := repo.generation
+	server.mutex.Unlock()
+
+	result := make(chan gitcontext.GitContext, 1)
+	go gitcontext.GetGitStateInDir(root, result)
+	gitContext := <-result
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if repo.watched && repo.generation == generation && gitContext.IsGitRepo {
+		repo.gitContext = gitContext
+		repo.cached = true
+	}
+	return gitContext, root
+}
+
+// Returns the repository with the given root, watching it if it was not visited recently
+func (server *server) getRepository(root string) *repository {
+	server.mutex.Lock()
+	repo, ok := server.repositories[root]
+	if ok {
+		repo.lastUsed = time.Now()
+		server.mutex.Unlock()
+		return repo
+	}
+
+	repo = &repository{root: root, lastUsed: time.Now(), watches: map[int32]bool{}}
+	server.repositories[root] = repo
+	if len(server.repositories) > MAX_REPOSITORIES {
+		server.evictLeastRecentlyUsedLocked()
+	}
+	server.mutex.Unlock()
+
+	server.watcher.watchRepository(repo)
+	return repo
+}
+
+func (server *server) evictLeastRecentlyUsedLocked() {
+	var oldest *repository
+	for _, repo := range server.repositories {
+		if oldest == nil || repo.lastUsed.Before(oldest.lastUsed) {
+			oldest = repo
+		}
+	}
+	server.removeRepositoryLocked(oldest)
+}
+
+func (server *server) removeRepositoryLocked(repo *repository) {
+	log.Debug().Msgf("No longer watching %s", repo.root)
+	server.watcher.unwatchRepositoryLocked(repo)
+	repo.removed = true
+	delete(server.repositories, repo.root)
+}
+
+// Stops watching the repositories which were not visited for a while
+func (server *server) evictIdleRepositories() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-server.stop:
+			return
+		case <-ticker.C:
+		}
+		server.mutex.Lock()
+		for _, repo := range server.repositories {
+			if time.Since(repo.lastUsed) > REPOSITORY_IDLE_TIMEOUT {
+				server.removeRepositoryLocked(repo)
+			}
+		}
+		server.mutex.Unlock()
+	}
+}
+
+func (server *server) getStatus() Status {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	status := Status{
+		PID:          os.Getpid(),
+		Version:      server.version,
+		StartedAt:    server.startedAt,
+		Repositories: []RepositoryStatus{},
+	}
+	for _, repo := range server.repositories {
+		status.Repositories = append(status.Repositories, RepositoryStatus{
+			Root:     repo.root,
+			Watches:  len(repo.watches),
+			Watched:  repo.watched,
+			Cached:   repo.cached,
+			LastUsed: repo.lastUsed,
+		})
+	}
+	sort.Slice(status.Repositories, func(i, j int) bool {
+		return status.Repositories[i].LastUsed.After(status.Repositories[j].LastUsed)
+	})
+	return status
+}
@@ -0,0 +1,298 @@
+package daemon
+
+// Watches the working tree and the git directory of repositories with inotify.
+// Any change in a watched directory invalidates the cached git context of the repository.
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"promptorium/internal/log"
+	"promptorium/internal/pkg/confpkg/context/gitcontext"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+var MAX_WATCHES_PER_REPOSITORY = 8192
+
+var WATCH_EVENTS uint32 = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY | unix.IN_ATTRIB | unix.IN_MOVED_FROM | unix.IN_MOVED_TO |
+	unix.IN_DELETE_SELF | unix.IN_MOVE_SELF | unix.IN_ONLYDIR | unix.IN_EXCL_UNLINK
+
+type watchKind int
+
+const (
+	watchWorkTree watchKind = iota
+	watchGitDir
+	watchRefs
+)
+
+type watch struct {
+	path         string
+	kind         watchKind
+	repositories map[*repository]bool
+}
+
+type watcher struct {
+	fd      int
+	mutex   *sync.Mutex
+	watches map[int32]*watch
+}
+
+// Returns a watcher sharing the mutex of the server, which protects the watches and the repositories
+func newWatcher(mutex *sync.Mutex) (*watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	return &watcher{fd: fd, mutex: mutex, watches: map[int32]*watch{}}, nil
+}
+
+// Watches the working tree, the git directory and the refs of the repository.
+// If the repository has too many directories, it is left unwatched and its git context is never cached.
+func (w *watcher) watchRepository(repo *repository) {
+	gitRepo, ok := gitcontext.FindRepository(repo.root)
+	if !ok {
+		return
+	}
+	workTreeDirs := getWorkTreeDirs(repo.root)
+	refsDirs := getDirs(filepath.Join(gitRepo.CommonDir, "refs"))
+	if len(workTreeDirs)+len(refsDirs)+2 > MAX_WATCHES_PER_REPOSITORY {
+		log.Debug().Msgf("Not watching %s: too many directories", repo.root)
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if repo.removed {
+		return
+	}
+	ok = w.addWatch(repo, gitRepo.GitDir, watchGitDir)
+	if gitRepo.CommonDir != gitRepo.GitDir {
+		// The packed refs of linked worktrees are in the common directory
+		ok = ok && w.addWatch(repo, gitRepo.CommonDir, watchGitDir)
+	}
+	for _, dir := range refsDirs {
+		ok = ok && w.addWatch(repo, dir, watchRefs)
+	}
+	for _, dir := range workTreeDirs {
+		ok = ok && w.addWatch(repo, dir, watchWorkTree)
+	}
+	if !ok {
+		w.unwatchRepositoryLocked(repo)
+		return
+	}
+	repo.watched = true
+	log.Debug().Msgf("Watching %s (%d directories)", repo.root, len(repo.watches))
+}
+
+// Removes the watches of the repository. The mutex must be held by the caller
+func (w *watcher) unwatchRepositoryLocked(repo *repository) {
+	for wd := range repo.watches {
+		watch, ok := w.watches[wd]
+		if !ok {
+			continue
+		}
+		delete(watch.repositories, repo)
+		if len(watch.repositories) == 0 {
+			unix.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.watches, wd)
+		}
+	}
+	repo.watches = map[int32]bool{}
+	repo.watched = false
+}
+
+// Adds a watch on a directory for the repository. The mutex must be held by the caller
+func (w *watcher) addWatch(repo *repository, path string, kind watchKind) bool {
+	wd, err := unix.InotifyAddWatch(w.fd, path, WATCH_EVENTS)
+	if err != nil {
+		log.Debug().Msgf("Could not watch %s: %s", path, err)
+		return false
+	}
+	existing, ok := w.watches[int32(wd)]
+	if !ok {
+		existing = &watch{path: path, kind: kind, repositories: map[*repository]bool{}}
+		w.watches[int32(wd)] = existing
+	}
+	existing.repositories[repo] = true
+	repo.watches[int32(wd)] = true
+	return true
+}
+
+// Reads the inotify events until the watcher is closed
+func (w *watcher) run() {
+	buffer := make([]byte, 64*1024)
+	for {
+		n, err := unix.Read(w.fd, buffer)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil || n <= 0 {
+			log.Debug().Msgf("Stopped reading inotify events: %v", err)
+			return
+		}
+		for _, dir := range w.handleEvents(buffer[:n]) {
+			w.watchNewDir(dir)
+		}
+	}
+}
+
+type newDir struct {
+	path         string
+	kind         watchKind
+	repositories []*repository
+}
+
+// Invalidates the repositories affected by the events, and returns the directories which were created in watched directories
+func (w *watcher) handleEvents(buffer []byte) []newDir {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	newDirs := []newDir{}
+	for offset := 0; offset+unix.SizeofInotifyEvent <= len(buffer); {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+		nameStart := offset + unix.SizeofInotifyEvent
+		name := string(bytes.TrimRight(buffer[nameStart:nameStart+int(event.Len)], "\x00"))
+		offset = nameStart + int(event.Len)
+
+		if event.Mask&unix.IN_Q_OVERFLOW != 0 {
+			// Events were lost, so none of the cached contexts can be trusted
+			log.Debug().Msg("Inotify event queue overflow")
+			for _, watch := range w.watches {
+				for repo := range watch.repositories {
+					repo.invalidate()
+				}
+			}
+			continue
+		}
+
+		watch, ok := w.watches[event.Wd]
+		if !ok {
+			continue
+		}
+		for repo := range watch.repositories {
+			repo.invalidate()
+		}
+
+		if event.Mask&unix.IN_IGNORED != 0 {
+			// The directory was removed, and its watch with it
+			for repo := range watch.repositories {
+				delete(repo.watches, event.Wd)
+			}
+			delete(w.watches, event.Wd)
+			continue
+		}
+
+		isNewDir := event.Mask&unix.IN_ISDIR != 0 && event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0
+		if isNewDir && watch.kind != watchGitDir && name != ".git" {
+			dir := newDir{path: filepath.Join(watch.path, name), kind: watch.kind}
+			for repo := range watch.repositories {
+				dir.repositories = append(dir.repositories, repo)
+			}
+			newDirs = append(newDirs, dir)
+		}
+	}
+	return newDirs
+}
+
+// Watches a directory created in a watched directory, and its subdirectories
+func (w *watcher) watchNewDir(dir newDir) {
+	dirs := []string{}
+	if dir.kind == watchRefs {
+		dirs = getDirs(dir.path)
+	} else {
+		if isNestedRepository(dir.path) || isIgnored(dir.path) {
+			return
+		}
+		dirs = getWorkTreeDirs(dir.path)
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for _, repo := range dir.repositories {
+		if !repo.watched {
+			// The repository was evicted in the meantime
+			continue
+		}
+		if len(repo.watches)+len(dirs) > MAX_WATCHES_PER_REPOSITORY {
+			log.Debug().Msgf("Not watching %s anymore: too many directories", repo.root)
+			w.unwatchRepositoryLocked(repo)
+			continue
+		}
+		for _, path := range dirs {
+			if !w.addWatch(repo, path, dir.kind) {
+				w.unwatchRepositoryLocked(repo)
+				break
+			}
+		}
+	}
+}
+
+func (w *watcher) close() {
+	unix.Close(w.fd)
+}
+
+// Returns the directories of the working tree, without the git directory, the ignored directories and the nested repositories
+func getWorkTreeDirs(root string) []string {
+	ignored := getIgnoredDirs(root)
+	dirs := []string{}
+	filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+		if entry.Name() == ".git" || ignored[path] {
+			return filepath.SkipDir
+		}
+		if path != root && isNestedRepository(path) {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	return dirs
+}
+
+// Returns the directory and all its subdirectories
+func getDirs(root string) []string {
+	dirs := []string{}
+	filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && entry.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	return dirs
+}
+
+// Returns the ignored directories of the working tree, such as build outputs, which don't change the git status
+func getIgnoredDirs(dir string) map[string]bool {
+	result := map[string]bool{}
+	cmd := exec.Command("git", "ls-files", "--others", "--ignored", "--exclude-standard", "--directory", "-z")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return result
+	}
+	for _, path := range strings.Split(string(output), "\x00") {
+		if strings.HasSuffix(path, "/") {
+			result[filepath.Join(dir, path)] = true
+		}
+	}
+	return result
+}
+
+func isIgnored(path string) bool {
+	cmd := exec.Command("git", "check-ignore", "-q", filepath.Base(path))
+	cmd.Dir = filepath.Dir(path)
+	return cmd.Run() == nil
+}
+
+func isNestedRepository(path string) bool {
+	_, err := os.Lstat(filepath.Join(path, ".git"))
+	return err == nil
+}
@@ -0,0 +1,31 @@
+package log
+
+// This package is the logger used by promptorium's internal packages.
+// It is disabled by default, so that using promptorium as a library doesn't write logs or depend on the zerolog global logger.
+// The promptorium command enables it by setting Logger at startup.
+
+import (
+	"github.com/rs/zerolog"
+)
+
+var Logger = zerolog.Nop()
+
+func Trace() *zerolog.Event {
+	return Logger.Trace()
+}
+
+func Debug() *zerolog.Event {
+	return Logger.Debug()
+}
+
+func Info() *zerolog.Event {
+	return Logger.Info()
+}
+
+func Warn() *zerolog.Event {
+	return Logger.Warn()
+}
+
+func Error() *zerolog.Event {
+	return Logger.Error()
+}
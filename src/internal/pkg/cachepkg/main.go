@@ -0,0 +1,46 @@
+package cachepkg
+
+import (
+	"fmt"
+	"os"
+	"promptorium/internal/pkg/confpkg/context/gitcontext"
+	"promptorium/internal/utils"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ShowCache returns a table describing the cached git contexts
+func ShowCache() (string, error) {
+	entries, err := gitcontext.GetCacheEntries()
+	if err != nil {
+		return "", err
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Cache directory: %s\n", gitcontext.GetCacheDir())
+	if len(entries) == 0 {
+		fmt.Fprintln(&builder, "No cached git repositories")
+		return builder.String(), nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key.Root < entries[j].Key.Root })
+
+	writer := tabwriter.NewWriter(&builder, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "REPOSITORY\tBRANCH\tAGE\tSTAGED\tUNSTAGED\tUNTRACKED")
+	for _, entry := range entries {
+		age := time.Since(entry.CreatedAt).Round(time.Second)
+		gitContext := entry.GitContext
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%d\t%d\t%d\n", entry.Key.Root, gitContext.LocalBranch, age, gitContext.StagedChanges, gitContext.UnstagedChanges, gitContext.UntrackedFiles)
+	}
+	writer.Flush()
+
+	return builder.String(), nil
+}
+
+// ClearCache removes the cached git contexts and the last known values of the context providers
+func ClearCache() error {
+	// The git cache is a subdirectory of the promptorium cache directory
+	return os.RemoveAll(utils.GetCacheDir())
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"bytes"
+	ctx "context"
+	"fmt"
+	"os/exec"
+	"promptorium/internal/log"
+	"promptorium/internal/utils"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+var DEFAULT_COMMAND_TIMEOUT = 500 * time.Millisecond
+
+// GetCommandContent runs the shell command in the component content and returns the first line of its output.
+// If the command fails, times out or prints nothing, the component is not displayed.
+func GetCommandContent(config *Config, component *Component) []ComponentContent {
+	result := []ComponentContent{}
+
+	if strings.TrimSpace(component.Content) == "" {
+		return result
+	}
+
+	timeout := component.Timeout
+	if timeout <= 0 {
+		timeout = DEFAULT_COMMAND_TIMEOUT
+	}
+
+	output, err := runWithTimeout(timeout, nil, "sh", "-c", component.Content)
+	if err != nil {
+		log.Debug().Msgf("Command of component %s failed: %s", component.Name, err)
+		return result
+	}
+
+	line, _, _ := strings.Cut(string(output), "\n")
+	line = strings.TrimRight(utils.StripANSI(line), "\r")
+	if line == "" {
+		return result
+	}
+
+	result = append(result, NewComponentContent(component, line, utf8.RuneCountInString(line)))
+	return result
+}
+
+// Runs an external program, killing it if it doesn't exit before the timeout
+func runWithTimeout(timeout time.Duration, input []byte, name string, args ...string) ([]byte, error) {
+	timeoutCtx, cancel := ctx.WithTimeout(ctx.Background(), timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(timeoutCtx, name, args...)
+	if input != nil {
+		cmd.Stdin = bytes.NewReader(input)
+	}
+	cmd.Stderr = &stderr
+	// Kill the whole process group, so that children of "sh -c" don't keep the output pipe open
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error { return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) }
+	cmd.WaitDelay = 10 * time.Millisecond
+	output, err := cmd.Output()
+	if timeoutCtx.Err() != nil {
+		return nil, fmt.Errorf("timed out after %s", timeout)
+	}
+	if err != nil {
+		if stderr.Len() > 0 {
+			return nil, fmt.Errorf("%s: %s", err, bytes.TrimSpace(stderr.Bytes()))
+		}
+		return nil, err
+	}
+	return output, nil
+}
@@ -22,6 +22,9 @@ func getDefaultTheme() Theme {
 		GitStatusColorNoUpstream:   Colors["yellow"],
 		ExitCodeColorOk:            Colors["green"],
 		ExitCodeColorError:         Colors["red"],
+		GitStateColorClean:         Colors["green"],
+		GitStateColorInProgress:    Colors["yellow"],
+		GitStateColorLocked:        Colors["red"],
 	}
 }
 
@@ -149,6 +152,9 @@ func getDefaultRawTheme() RawTheme {
 		GitStatusColorNoUpstream:   "yellow",
 		ExitCodeColorOk:            "green",
 		ExitCodeColorError:         "red",
+		GitStateColorClean:         "green",
+		GitStateColorInProgress:    "yellow",
+		GitStateColorLocked:        "red",
 	}
 }
 
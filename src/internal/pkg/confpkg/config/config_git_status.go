@@ -0,0 +1,209 @@
+package config
+
+import (
+	"promptorium/internal/log"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The git_status and vcs_status modules render a format string, in which each placeholder is replaced by its symbol and its count.
+// Placeholders with a count of zero are hidden, together with the separator which follows them.
+
+var DEFAULT_GIT_STATUS_FORMAT = "{clean}{conflicted} {staged} {unstaged} {untracked} {stashed} {ahead} {behind}"
+
+var GIT_STATUS_PLACEHOLDERS = []string{"staged", "unstaged", "untracked", "conflicted", "stashed", "ahead", "behind", "clean"}
+
+func getDefaultGitStatusPlaceholders() map[string]GitStatusPlaceholder {
+	return map[string]GitStatusPlaceholder{
+		"staged":     {Symbol: "+"},
+		"unstaged":   {Symbol: "!"},
+		"untracked":  {Symbol: "?"},
+		"conflicted": {Symbol: "=", Color: "$error_color"},
+		"stashed":    {Symbol: "$"},
+		"ahead":      {Symbol: "↑"},
+		"behind":     {Symbol: "↓", Color: "$error_color"},
+		"clean":      {Symbol: "✓", Color: "$success_color"},
+	}
+}
+
+func parseGitStatusOptions(options RawGitStatusOptions) GitStatusOptions {
+	result := GitStatusOptions{
+		Format:       options.Format,
+		Placeholders: getDefaultGitStatusPlaceholders(),
+	}
+	if result.Format == "" {
+		result.Format = DEFAULT_GIT_STATUS_FORMAT
+	}
+
+	rawPlaceholders := map[string]RawGitStatusPlaceholder{
+		"staged":     options.Staged,
+		"unstaged":   options.Unstaged,
+		"untracked":  options.Untracked,
+		"conflicted": options.Conflicted,
+		"stashed":    options.Stashed,
+		"ahead":      options.Ahead,
+		"behind":     options.Behind,
+		"clean":      options.Clean,
+	}
+	for name, rawPlaceholder := range rawPlaceholders {
+		placeholder := result.Placeholders[name]
+		if rawPlaceholder.Symbol != nil {
+			placeholder.Symbol = *rawPlaceholder.Symbol
+		}
+		if rawPlaceholder.Color != "" {
+			placeholder.Color = rawPlaceholder.Color
+		}
+		result.Placeholders[name] = placeholder
+	}
+	return result
+}
+
+// A piece of the format string: either literal text or a placeholder
+type formatToken struct {
+	text        string
+	placeholder string
+}
+
+// Splits the format string into literal text and placeholders. Unknown placeholders are kept as text
+func parseGitStatusFormat(format string) []formatToken {
+	tokens := []formatToken{}
+	text := ""
+	for len(format) > 0 {
+		start := strings.Index(format, "{")
+		if start < 0 {
+			text += format
+			break
+		}
+		end := strings.Index(format[start:], "}")
+		if end < 0 {
+			text += format
+			break
+		}
+		end += start
+		name := format[start+1 : end]
+		isPlaceholder := false
+		for _, placeholder := range GIT_STATUS_PLACEHOLDERS {
+			isPlaceholder = isPlaceholder || placeholder == name
+		}
+		if !isPlaceholder {
+			text += format[:end+1]
+			format = format[end+1:]
+			continue
+		}
+		if text+format[:start] != "" {
+			tokens = append(tokens, formatToken{text: text + format[:start]})
+		}
+		text = ""
+		tokens = append(tokens, formatToken{placeholder: name})
+		format = format[end+1:]
+	}
+	if text != "" {
+		tokens = append(tokens, formatToken{text: text})
+	}
+	return tokens
+}
+
+func getGitStatusModuleContent(config *Config, component *Component) []ComponentContent {
+	result := []ComponentContent{}
+	gitState := config.Context.GitContext.GetContent()
+	if !gitState.IsGitRepo {
+		return result
+	}
+	if gitState.LocalBranch == "" && !gitState.IsDetachedHead {
+		return result
+	}
+
+	isClean := gitState.StagedChanges == 0 && gitState.UnstagedChanges == 0 && gitState.UntrackedFiles == 0 && gitState.ConflictedFiles == 0
+	values := map[string]int{
+		"staged":     gitState.StagedChanges,
+		"unstaged":   gitState.UnstagedChanges,
+		"untracked":  gitState.UntrackedFiles,
+		"conflicted": gitState.ConflictedFiles,
+		"stashed":    gitState.Stashes,
+		"ahead":      gitState.Ahead,
+		"behind":     gitState.Behind,
+	}
+	if isClean {
+		values["clean"] = 1
+	}
+	return getStatusContent(config, component, values)
+}
+
+func getVCSStatusModuleContent(config *Config, component *Component) []ComponentContent {
+	vcsContext := config.Context.VCSContext.GetContent()
+	if !vcsContext.IsRepo {
+		return []ComponentContent{}
+	}
+
+	values := map[string]int{
+		"staged":     vcsContext.StagedChanges,
+		"unstaged":   vcsContext.UnstagedChanges,
+		"untracked":  vcsContext.UntrackedFiles,
+		"conflicted": vcsContext.ConflictedFiles,
+		"stashed":    vcsContext.Stashes,
+		"ahead":      vcsContext.Ahead,
+		"behind":     vcsContext.Behind,
+	}
+	if vcsContext.StagedChanges == 0 && vcsContext.UnstagedChanges == 0 && vcsContext.UntrackedFiles == 0 && vcsContext.ConflictedFiles == 0 {
+		values["clean"] = 1
+	}
+	return getStatusContent(config, component, values)
+}
+
+// Renders the git_status format with the counts of the placeholders
+func getStatusContent(config *Config, component *Component, values map[string]int) []ComponentContent {
+	result := []ComponentContent{}
+	tokens := parseGitStatusFormat(config.Options.GitStatus.Format)
+	isVisible := func(i int) bool {
+		return tokens[i].placeholder != "" && values[tokens[i].placeholder] > 0
+	}
+	// Returns whether the literal text at index i is between two placeholders, and whether the next placeholder is visible
+	getNextPlaceholder := func(i int) (bool, bool) {
+		for j := i + 1; j < len(tokens); j++ {
+			if tokens[j].placeholder != "" {
+				return true, isVisible(j)
+			}
+		}
+		return false, false
+	}
+
+	var builder strings.Builder
+	length := 0
+	hasVisiblePlaceholder := false
+	hasPlaceholderBefore := false
+	for i, token := range tokens {
+		if token.placeholder == "" {
+			hasPlaceholderAfter, isNextVisible := getNextPlaceholder(i)
+			isSeparator := hasPlaceholderBefore && hasPlaceholderAfter
+			if isSeparator && (!hasVisiblePlaceholder || !isNextVisible) {
+				continue
+			}
+			builder.WriteString(config.ColorizeString(token.text, component.Style.ForegroundColor, component.Style.BackgroundColor))
+			length += utf8.RuneCountInString(token.text)
+			continue
+		}
+
+		hasPlaceholderBefore = true
+		if !isVisible(i) {
+			continue
+		}
+		hasVisiblePlaceholder = true
+		placeholder := config.Options.GitStatus.Placeholders[token.placeholder]
+		text := placeholder.Symbol
+		if token.placeholder != "clean" {
+			text += strconv.Itoa(values[token.placeholder])
+		}
+		color := parseColor(placeholder.Color, config.Theme, "git status "+token.placeholder, component.Style.ForegroundColor, config.Context)
+		builder.WriteString(config.ColorizeString(text, color, component.Style.BackgroundColor))
+		length += utf8.RuneCountInString(text)
+	}
+
+	if !hasVisiblePlaceholder {
+		log.Trace().Msg("Nothing to display in the git status")
+		return result
+	}
+
+	result = append(result, NewComponentContent(component, builder.String(), length))
+	return result
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"promptorium/internal/pkg/confpkg/context"
+	"reflect"
+	"regexp"
+	"testing"
+	"unicode/utf8"
+)
+
+var ansiEscapePattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func TestParseGitStatusFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		want   []formatToken
+	}{
+		{"", []formatToken{}},
+		{"{staged}", []formatToken{{placeholder: "staged"}}},
+		{"{clean}{conflicted} {staged}", []formatToken{{placeholder: "clean"}, {placeholder: "conflicted"}, {text: " "}, {placeholder: "staged"}}},
+		{"[{ahead}|{behind}]", []formatToken{{text: "["}, {placeholder: "ahead"}, {text: "|"}, {placeholder: "behind"}, {text: "]"}}},
+		{"{unknown} {staged}", []formatToken{{text: "{unknown} "}, {placeholder: "staged"}}},
+		{"{staged} {unclosed", []formatToken{{placeholder: "staged"}, {text: " {unclosed"}}},
+		{"text only", []formatToken{{text: "text only"}}},
+	}
+	for _, test := range tests {
+		got := parseGitStatusFormat(test.format)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseGitStatusFormat(%q) = %+v, want %+v", test.format, got, test.want)
+		}
+	}
+}
+
+func TestGetStatusContent(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		values map[string]int
+		want   string
+	}{
+		{"all zero", "{staged} {unstaged} {untracked}", map[string]int{}, ""},
+		{"all zero with text around", "[{staged} {unstaged}]", map[string]int{}, ""},
+		{"clean", "{clean}{conflicted} {staged}", map[string]int{"clean": 1}, "✓"},
+		{"all visible", "{staged} {unstaged} {untracked}", map[string]int{"staged": 1, "unstaged": 2, "untracked": 3}, "+1 !2 ?3"},
+		{"leading zero", "{staged} {unstaged} {untracked}", map[string]int{"unstaged": 2, "untracked": 3}, "!2 ?3"},
+		{"trailing zero", "{staged} {unstaged} {untracked}", map[string]int{"staged": 1, "unstaged": 2}, "+1 !2"},
+		{"middle zero", "{staged} {unstaged} {untracked}", map[string]int{"staged": 1, "untracked": 3}, "+1 ?3"},
+		{"adjacent zeros", "{staged} {unstaged} {untracked} {stashed}", map[string]int{"staged": 1, "stashed": 4}, "+1 $4"},
+		{"only one visible", "{staged} {unstaged} {untracked} {stashed}", map[string]int{"untracked": 3}, "?3"},
+		{"text around", "[{ahead}|{behind}]", map[string]int{"behind": 5}, "[↓5]"},
+		{"placeholders without separator", "{ahead}{behind}", map[string]int{"ahead": 1, "behind": 2}, "↑1↓2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			theme := getDefaultTheme()
+			config := &Config{
+				Context: context.NewApplicationContextFromSnapshot(context.Snapshot{Shell: context.ShellOther}),
+				Theme:   theme,
+				Options: ConfigOptions{GitStatus: parseGitStatusOptions(RawGitStatusOptions{Format: test.format})},
+			}
+			component := &Component{Style: ComponentStyle{ForegroundColor: theme.ForegroundColor, BackgroundColor: theme.PrimaryColor}}
+
+			result := getStatusContent(config, component, test.values)
+			if test.want == "" {
+				if len(result) != 0 {
+					t.Fatalf("got %+v, want no content", result)
+				}
+				return
+			}
+			if len(result) != 1 {
+				t.Fatalf("got %d contents, want 1", len(result))
+			}
+			got := ansiEscapePattern.ReplaceAllString(result[0].Str, "")
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+			if result[0].Len != utf8.RuneCountInString(test.want) {
+				t.Errorf("got length %d, want %d", result[0].Len, utf8.RuneCountInString(test.want))
+			}
+		})
+	}
+}
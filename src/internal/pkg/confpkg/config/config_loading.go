@@ -4,34 +4,90 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"promptorium/internal/log"
 	"promptorium/internal/pkg/confpkg/context"
 
-	"github.com/rs/zerolog/log"
 	"gopkg.in/yaml.v3"
 )
 
 func GetRawConfig(configPath string, context *context.ApplicationContext, version string) RawConfig {
 	// Load raw config
-	path := getConfigPath(configPath)
+	path := getConfigPath(configPath, context)
 
 	log.Trace().Msgf("Loading config")
 
-	rawConfig := loadRawComponents(path)
-	rawTheme := loadRawTheme(path)
-	rawOptions := loadRawOptions(path)
-	rawPrompt := loadRawPrompt(path)
+	configFile, err := os.ReadFile(path)
+	if err != nil {
+		log.Trace().Msg("Could not read config file, using default config")
+		configFile = nil
+	}
+
+	return getRawConfigFromFile(configFile, filepath.Dir(path), context, version)
+}
+
+// ReadConfigFile reads the config file at configPath, or the default config file if configPath is empty,
+// and returns its content with its path. Unlike GetRawConfig, it doesn't fall back to the default config.
+func ReadConfigFile(configPath string) ([]byte, string, error) {
+	if configPath == "" {
+		var err error
+		configPath, err = findFile(DEFAULT_CONFIG_PATH, []string{"config.yaml", "config.yml", "config.json"})
+		if err != nil {
+			return nil, "", fmt.Errorf("could not find a config file in %s", DEFAULT_CONFIG_PATH)
+		}
+	}
+	configFile, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, configPath, fmt.Errorf("could not read config file: %w", err)
+	}
+	err = CheckConfigFile(configFile)
+	if err != nil {
+		return nil, configPath, fmt.Errorf("%s: %w", configPath, err)
+	}
+	return configFile, configPath, nil
+}
+
+// CheckConfigFile returns an error if the content of the config file is not a valid YAML or JSON document
+func CheckConfigFile(configFile []byte) error {
+	var content map[string]any
+	err := yaml.Unmarshal(configFile, &content)
+	if err != nil {
+		return fmt.Errorf("could not parse config file: %w", err)
+	}
+	return nil
+}
+
+// GetRawConfigFromBytes parses the raw config from the content of a config file.
+// Relative paths to the components, theme, options and prompt files are resolved from baseDir.
+func GetRawConfigFromBytes(configFile []byte, baseDir string, context *context.ApplicationContext, version string) RawConfig {
+	presetPath, ok := getPresetConfigPath(configFile, context)
+	if ok {
+		return GetRawConfig(presetPath, context, version)
+	}
+
+	log.Trace().Msgf("Loading config from bytes")
+
+	return getRawConfigFromFile(configFile, baseDir, context, version)
+}
+
+func getRawConfigFromFile(configFile []byte, baseDir string, context *context.ApplicationContext, version string) RawConfig {
+	rawConfig := loadRawComponents(configFile, baseDir, context)
+	rawTheme := loadRawTheme(configFile, baseDir)
+	rawOptions := loadRawOptions(configFile, baseDir)
+	rawPrompt := loadRawPrompt(configFile, baseDir)
+	rawTransientPrompt := loadRawTransientPrompt(configFile)
 	return RawConfig{
-		Version:    version,
-		Context:    context,
-		Components: rawConfig,
-		Theme:      rawTheme,
-		Options:    rawOptions,
-		Prompt:     rawPrompt,
+		Version:         version,
+		Context:         context,
+		Components:      rawConfig,
+		Theme:           rawTheme,
+		Options:         rawOptions,
+		Prompt:          rawPrompt,
+		TransientPrompt: rawTransientPrompt,
 	}
 }
 
-// Loads config from the configPath file in the raw format
-func loadRawComponents(configPath string) []RawComponent {
+// Loads components from the config file in the raw format
+func loadRawComponents(configFile []byte, baseDir string, context *context.ApplicationContext) []RawComponent {
 	type RawConfigComponents struct {
 		Components []RawComponent `yaml:"components"`
 	}
@@ -41,34 +97,33 @@ func loadRawComponents(configPath string) []RawComponent {
 	}
 	var componentsPath rawConfigComponentsString
 
-	config_file, err := os.ReadFile(configPath)
-	if err != nil {
-		log.Trace().Msg("Could not read config file, using default config")
+	if configFile == nil {
 		return getDefaultRawComponents()
 	}
-	err = yaml.Unmarshal(config_file, &componentsPath)
+	yaml.Unmarshal(configFile, &componentsPath)
 	if componentsPath.Components != "" {
 		if !filepath.IsAbs(componentsPath.Components) {
-			componentsPath.Components = filepath.Join(filepath.Dir(configPath), componentsPath.Components)
+			componentsPath.Components = filepath.Join(baseDir, componentsPath.Components)
 		}
 		log.Info().Msgf("Loading config from %s", componentsPath.Components)
-		config_file, err = os.ReadFile(componentsPath.Components)
+		var err error
+		configFile, err = os.ReadFile(componentsPath.Components)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "promptorium: Could not read config file, using default config")
+			context.ReportProblem(fmt.Errorf("could not read config file %s, using default config: %w", componentsPath.Components, err))
 			return getDefaultRawComponents()
 		}
 	}
-	err = yaml.Unmarshal(config_file, &rawConf)
+	err := yaml.Unmarshal(configFile, &rawConf)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "promptorium: Could not parse config file, using default config")
+		context.ReportProblem(fmt.Errorf("could not parse config file, using default config: %w", err))
 		return getDefaultRawComponents()
 	}
 
 	return rawConf.Components
 }
 
-// Loads theme from the themePath file in the raw format
-func loadRawTheme(themePath string) RawTheme {
+// Loads theme from the config file in the raw format
+func loadRawTheme(configFile []byte, baseDir string) RawTheme {
 	type rawConfigTheme struct {
 		Theme RawTheme `yaml:"theme"`
 	}
@@ -79,22 +134,18 @@ func loadRawTheme(themePath string) RawTheme {
 	rawConfigThemeString := RawConfigThemeString{}
 	theme := rawConfigTheme{}
 
-	if themePath == "" {
-		log.Trace().Msg("Theme path is empty, using default theme path")
-		themePath, _ = findFile(DEFAULT_CONFIG_PATH, []string{"config.yaml", "config.yml", "config.json", "conf.yaml", "conf.yml", "conf.json"})
-	}
-	// Look for conf.yaml, conf.yml or conf.json in the theme path
-	themeFile, err := os.ReadFile(themePath)
-	if err != nil {
+	if configFile == nil {
 		log.Trace().Msg("Could not read theme file, using default theme")
 		return getDefaultRawTheme()
 	}
-	err = yaml.Unmarshal(themeFile, &rawConfigThemeString)
+	themeFile := configFile
+	yaml.Unmarshal(themeFile, &rawConfigThemeString)
 	if rawConfigThemeString.Theme != "" {
 		if !filepath.IsAbs(rawConfigThemeString.Theme) {
-			rawConfigThemeString.Theme = filepath.Join(filepath.Dir(themePath), rawConfigThemeString.Theme)
+			rawConfigThemeString.Theme = filepath.Join(baseDir, rawConfigThemeString.Theme)
 		}
 		log.Info().Msgf("Loading theme from %s", rawConfigThemeString.Theme)
+		var err error
 		themeFile, err = os.ReadFile(rawConfigThemeString.Theme)
 		if err != nil {
 			log.Trace().Msg("Could not read theme file, using default theme")
@@ -102,7 +153,7 @@ func loadRawTheme(themePath string) RawTheme {
 		}
 	}
 
-	err = yaml.Unmarshal(themeFile, &theme)
+	err := yaml.Unmarshal(themeFile, &theme)
 	if err != nil {
 		log.Trace().Msg("Could not unmarshal theme file, using default theme")
 		return getDefaultRawTheme()
@@ -110,7 +161,7 @@ func loadRawTheme(themePath string) RawTheme {
 	return theme.Theme
 }
 
-func loadRawOptions(optionsPath string) RawOptions {
+func loadRawOptions(configFile []byte, baseDir string) RawOptions {
 	type RawConfigOptions struct {
 		Options RawOptions `yaml:"options"`
 	}
@@ -121,23 +172,19 @@ func loadRawOptions(optionsPath string) RawOptions {
 	rawConfigOptionsString := RawConfigOptionsString{}
 
 	rawConfigOptions := RawConfigOptions{}
-	if optionsPath == "" {
-		log.Trace().Msg("Options path is empty, using default options path")
-		optionsPath, _ = findFile(DEFAULT_CONFIG_PATH, []string{"config.yaml", "config.yml", "config.json, conf.yaml", "conf.yml", "conf.json"})
-	}
-	// Look for conf.yaml, conf.yml or conf.json in the options path
-	optionsFile, err := os.ReadFile(optionsPath)
-	if err != nil {
+	if configFile == nil {
 		log.Trace().Msg("Could not read options file, using default options")
 		return rawConfigOptions.Options
 	}
 
-	err = yaml.Unmarshal(optionsFile, &rawConfigOptionsString)
+	optionsFile := configFile
+	yaml.Unmarshal(optionsFile, &rawConfigOptionsString)
 	if rawConfigOptionsString.Options != "" {
 		if !filepath.IsAbs(rawConfigOptionsString.Options) {
-			rawConfigOptionsString.Options = filepath.Join(filepath.Dir(optionsPath), rawConfigOptionsString.Options)
+			rawConfigOptionsString.Options = filepath.Join(baseDir, rawConfigOptionsString.Options)
 		}
 		log.Info().Msgf("Loading options from %s", rawConfigOptionsString.Options)
+		var err error
 		optionsFile, err = os.ReadFile(rawConfigOptionsString.Options)
 		if err != nil {
 			log.Trace().Msg("Could not read options file, using default options")
@@ -145,7 +192,7 @@ func loadRawOptions(optionsPath string) RawOptions {
 		}
 	}
 
-	err = yaml.Unmarshal(optionsFile, &rawConfigOptions)
+	err := yaml.Unmarshal(optionsFile, &rawConfigOptions)
 	if err != nil {
 		log.Trace().Msg("Could not unmarshal options file, using default options")
 		return rawConfigOptions.Options
@@ -153,7 +200,7 @@ func loadRawOptions(optionsPath string) RawOptions {
 	return rawConfigOptions.Options
 }
 
-func loadRawPrompt(promptPath string) [][]string {
+func loadRawPrompt(configFile []byte, baseDir string) [][]string {
 	type RawConfigPrompt struct {
 		Prompt []string `yaml:"prompt"`
 	}
@@ -168,27 +215,22 @@ func loadRawPrompt(promptPath string) [][]string {
 	rawConfigMultilinePrompt := RawConfigMultilinePrompt{}
 	rawConfigPromptString := RawConfigPromptString{}
 
-	if promptPath == "" {
-		log.Trace().Msg("Prompt path is empty, using default prompt path")
-		promptPath, _ = findFile(DEFAULT_CONFIG_PATH, []string{"config.yaml", "config.yml", "config.json, conf.yaml", "conf.yml", "conf.json"})
-	}
-	// Look for conf.yaml, conf.yml or conf.json in the prompt path
-	promptFile, err := os.ReadFile(promptPath)
-	if err != nil {
+	if configFile == nil {
 		log.Trace().Msg("Could not read prompt file, using default prompt")
 		return defaultPrompt
 	}
+	promptFile := configFile
 
 	// Try to unmarshal prompt field as a string, if it fails, try to unmarshal as a struct
 
-	err = yaml.Unmarshal(promptFile, &rawConfigPromptString)
+	yaml.Unmarshal(promptFile, &rawConfigPromptString)
 	if rawConfigPromptString.Prompt != "" {
 		// If the prompt field is a string, look for the file in the specified path
 		if !filepath.IsAbs(rawConfigPromptString.Prompt) {
-			rawConfigPromptString.Prompt = filepath.Join(filepath.Dir(promptPath), rawConfigPromptString.Prompt)
+			rawConfigPromptString.Prompt = filepath.Join(baseDir, rawConfigPromptString.Prompt)
 		}
 		log.Info().Msgf("Loading prompt from %s", rawConfigPromptString.Prompt)
-		promptFile, err = os.ReadFile(rawConfigPromptString.Prompt)
+		_, err := os.ReadFile(rawConfigPromptString.Prompt)
 		if err != nil {
 			log.Trace().Msg("Could not read prompt file, using default prompt")
 			return defaultPrompt
@@ -197,7 +239,7 @@ func loadRawPrompt(promptPath string) [][]string {
 	}
 
 	// Try to unmarshal the prompt as an array of strings
-	err = yaml.Unmarshal(promptFile, &rawConfigMultilinePrompt)
+	err := yaml.Unmarshal(promptFile, &rawConfigMultilinePrompt)
 	if err != nil {
 		err = yaml.Unmarshal(promptFile, &rawConfigPrompt)
 		if err != nil {
@@ -211,6 +253,42 @@ func loadRawPrompt(promptPath string) [][]string {
 
 }
 
+// HasTransientPrompt returns whether the config file sets a transient_prompt, so that the shell scripts only redraw
+// the prompt when there is one
+func HasTransientPrompt(configPath string) bool {
+	configFile, err := os.ReadFile(getConfigPath(configPath, nil))
+	if err != nil {
+		return false
+	}
+	return len(loadRawTransientPrompt(configFile)) > 0
+}
+
+// Loads the layout of the transient prompt, which has the same format as the prompt. It is nil if not set
+func loadRawTransientPrompt(configFile []byte) [][]string {
+	type RawConfigTransientPrompt struct {
+		TransientPrompt []string `yaml:"transient_prompt"`
+	}
+	type RawConfigMultilineTransientPrompt struct {
+		TransientPrompt [][]string `yaml:"transient_prompt"`
+	}
+	if configFile == nil {
+		return nil
+	}
+
+	rawConfigMultilineTransientPrompt := RawConfigMultilineTransientPrompt{}
+	err := yaml.Unmarshal(configFile, &rawConfigMultilineTransientPrompt)
+	if err != nil {
+		rawConfigTransientPrompt := RawConfigTransientPrompt{}
+		err = yaml.Unmarshal(configFile, &rawConfigTransientPrompt)
+		if err != nil || rawConfigTransientPrompt.TransientPrompt == nil {
+			log.Trace().Msg("Could not unmarshal transient prompt")
+			return nil
+		}
+		return [][]string{rawConfigTransientPrompt.TransientPrompt}
+	}
+	return rawConfigMultilineTransientPrompt.TransientPrompt
+}
+
 /*
  * Helper functions
  */
@@ -228,32 +306,42 @@ func findFile(dir string, files []string) (string, error) {
 	return "", fmt.Errorf("promptorium: Could not find file")
 }
 
-func getConfigPath(configPath string) string {
+func getConfigPath(configPath string, context *context.ApplicationContext) string {
 	if configPath == "" {
 		log.Trace().Msg("Config path is empty, using default config path")
 		configPath, _ = findFile(DEFAULT_CONFIG_PATH, []string{"config.yaml", "config.yml", "config.json"})
 	}
 
+	config_file, err := os.ReadFile(configPath)
+	if err != nil {
+		log.Trace().Msg("Could not read config file")
+		return configPath
+	}
+
+	presetPath, ok := getPresetConfigPath(config_file, context)
+	if !ok {
+		return configPath
+	}
+	return presetPath
+}
+
+// Returns the path of the config file of the preset set in the config file, if any
+func getPresetConfigPath(config_file []byte, context *context.ApplicationContext) (string, bool) {
 	type rawConfigPreset struct {
 		Preset string `yaml:"preset"`
 	}
 
 	rawConf := rawConfigPreset{}
 
-	config_file, err := os.ReadFile(configPath)
-	if err != nil {
-		log.Trace().Msg("Could not read config file")
-		return configPath
-	}
-	err = yaml.Unmarshal(config_file, &rawConf)
+	err := yaml.Unmarshal(config_file, &rawConf)
 	if err != nil {
 		log.Trace().Msg("No preset found in config file")
-		return configPath
+		return "", false
 	}
 
 	if rawConf.Preset == "" {
 		log.Trace().Msg("No preset found in config file")
-		return configPath
+		return "", false
 	}
 
 	log.Trace().Msgf("Found preset %s in config file", rawConf.Preset)
@@ -261,18 +349,18 @@ func getConfigPath(configPath string) string {
 	_, err = os.Stat(filepath.Join(DEFAULT_PRESET_PATH, rawConf.Preset))
 	if err != nil {
 		log.Trace().Msgf("Could not find preset directory %s", filepath.Join(DEFAULT_PRESET_PATH, rawConf.Preset))
-		fmt.Fprintln(os.Stderr, "promptorium: Could not find preset directory", filepath.Join(DEFAULT_PRESET_PATH, rawConf.Preset))
-		return configPath
+		context.ReportProblem(fmt.Errorf("could not find preset directory %s", filepath.Join(DEFAULT_PRESET_PATH, rawConf.Preset)))
+		return "", false
 	}
 
-	configPath, err = findFile(filepath.Join(DEFAULT_PRESET_PATH, rawConf.Preset), []string{"config.yaml", "config.yml", "config.json"})
+	configPath, err := findFile(filepath.Join(DEFAULT_PRESET_PATH, rawConf.Preset), []string{"config.yaml", "config.yml", "config.json"})
 	if err != nil {
 		log.Trace().Msgf("Could not find preset config file in directory %s", filepath.Join(DEFAULT_PRESET_PATH, rawConf.Preset))
-		fmt.Fprintln(os.Stderr, "promptorium: Could not find preset config file in directory %s", filepath.Join(DEFAULT_PRESET_PATH, rawConf.Preset))
-		return configPath
+		context.ReportProblem(fmt.Errorf("could not find preset config file in directory %s", filepath.Join(DEFAULT_PRESET_PATH, rawConf.Preset)))
+		return "", false
 	}
 	log.Trace().Msgf("Using preset config file %s", configPath)
-	return configPath
+	return configPath, true
 }
 
 /*
@@ -286,6 +374,8 @@ type RawConfig struct {
 	Theme      RawTheme
 	Options    RawOptions
 	Prompt     [][]string
+	// Layout drawn in place of the prompt once a command is accepted
+	TransientPrompt [][]string
 }
 
 type RawColorName string
@@ -294,6 +384,7 @@ type RawComponent struct {
 	Name    string            `yaml:"name"`
 	Type    RawComponentType  `yaml:"type"`
 	Content string            `yaml:"content"`
+	Timeout string            `yaml:"timeout,omitempty"`
 	Style   RawComponentStyle `yaml:"style"`
 }
 
@@ -302,9 +393,10 @@ type RawIcon string
 type RawComponentType string
 
 var RawComponentTypes = map[string]RawComponentType{
-	"module": RawComponentType("module"),
-	"plugin": RawComponentType("plugin"),
-	"text":   RawComponentType("text"),
+	"module":  RawComponentType("module"),
+	"plugin":  RawComponentType("plugin"),
+	"text":    RawComponentType("text"),
+	"command": RawComponentType("command"),
 }
 
 type RawComponentStyle struct {
@@ -350,12 +442,81 @@ type RawTheme struct {
 	GitStatusColorNoUpstream   RawColorName `yaml:"git_status_no_upstream,omitempty"`
 	ExitCodeColorOk            RawColorName `yaml:"exit_code_ok,omitempty"`
 	ExitCodeColorError         RawColorName `yaml:"exit_code_error,omitempty"`
+	GitStateColorClean         RawColorName `yaml:"git_state_clean,omitempty"`
+	GitStateColorInProgress    RawColorName `yaml:"git_state_in_progress,omitempty"`
+	GitStateColorLocked        RawColorName `yaml:"git_state_locked,omitempty"`
+	// Icons of the os_icon and os modules, by os-release ID
+	OSIcons map[string]string `yaml:"os_icons,omitempty"`
 }
 
 type RawOptions struct {
-	CWD RawCwdOptions `yaml:"cwd"`
+	CWD         RawCwdOptions         `yaml:"cwd"`
+	Git         RawGitOptions         `yaml:"git"`
+	GitStatus   RawGitStatusOptions   `yaml:"git_status"`
+	GitRemote   RawGitRemoteOptions   `yaml:"git_remote"`
+	OS          RawOSOptions          `yaml:"os"`
+	CmdDuration RawCmdDurationOptions `yaml:"cmd_duration"`
+	Jobs        RawJobsOptions        `yaml:"jobs"`
+	ExitStatus  RawExitStatusOptions  `yaml:"exit_status"`
+	Timeouts    RawTimeoutOptions     `yaml:"timeouts"`
 }
 
 type RawCwdOptions struct {
-	HighlightGitRoot bool `yaml:"highlight_git_root"`
+	HighlightGitRoot          bool `yaml:"highlight_git_root"`
+	HighlightSuperprojectRoot bool `yaml:"highlight_superproject_root"`
+}
+
+type RawGitOptions struct {
+	Cache       bool   `yaml:"cache"`
+	CacheMaxAge string `yaml:"cache_max_age"`
+}
+
+type RawGitStatusOptions struct {
+	Format     string                  `yaml:"format"`
+	Staged     RawGitStatusPlaceholder `yaml:"staged"`
+	Unstaged   RawGitStatusPlaceholder `yaml:"unstaged"`
+	Untracked  RawGitStatusPlaceholder `yaml:"untracked"`
+	Conflicted RawGitStatusPlaceholder `yaml:"conflicted"`
+	Stashed    RawGitStatusPlaceholder `yaml:"stashed"`
+	Ahead      RawGitStatusPlaceholder `yaml:"ahead"`
+	Behind     RawGitStatusPlaceholder `yaml:"behind"`
+	Clean      RawGitStatusPlaceholder `yaml:"clean"`
+}
+
+type RawGitStatusPlaceholder struct {
+	Symbol *string      `yaml:"symbol"`
+	Color  RawColorName `yaml:"color"`
+}
+
+type RawGitRemoteOptions struct {
+	Mode  string            `yaml:"mode"`
+	Hosts map[string]string `yaml:"hosts"`
+}
+
+type RawOSOptions struct {
+	Format string `yaml:"format"`
+}
+
+type RawCmdDurationOptions struct {
+	MinTime  string `yaml:"min_time"`
+	WarnTime string `yaml:"warn_time"`
+}
+
+type RawJobsOptions struct {
+	Symbol    *string `yaml:"symbol"`
+	Threshold int     `yaml:"threshold"`
+	ShowZero  bool    `yaml:"show_zero"`
+}
+
+type RawExitStatusOptions struct {
+	Pipeline  bool    `yaml:"pipeline"`
+	Separator *string `yaml:"separator"`
+	Names     *bool   `yaml:"names"`
+	Pipefail  bool    `yaml:"pipefail"`
+}
+
+type RawTimeoutOptions struct {
+	Prompt    string            `yaml:"prompt"`
+	Providers map[string]string `yaml:"providers"`
+	Marker    *string           `yaml:"marker"`
 }
@@ -1,29 +1,189 @@
 package config
 
 import (
-	"os"
+	"fmt"
+	"promptorium/internal/log"
+	"promptorium/internal/pkg/confpkg/context"
+	"promptorium/internal/pkg/confpkg/context/gitcontext"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 	"unicode/utf8"
 
-	"github.com/rs/zerolog/log"
+	"golang.org/x/sys/unix"
 )
 
-func loadModules() map[string]ModuleEntry {
+// GetModuleRegistry returns a new registry containing the built-in modules
+func GetModuleRegistry() ModuleRegistry {
+	return loadModules()
+}
+
+func loadModules() ModuleRegistry {
 	log.Trace().Msgf("Loading modules")
-	modules := make(map[string]ModuleEntry)
+	modules := ModuleRegistry{}
 	// Load modules
-	modules["git_branch"] = ModuleEntry{Get: getGitBranchModuleContent}
-	modules["hostname"] = ModuleEntry{Get: getHostnameModuleContent}
-	modules["time"] = ModuleEntry{Get: getTimeModuleContent}
-	modules["cwd"] = ModuleEntry{Get: getCwdModuleContent}
-	modules["user"] = ModuleEntry{Get: getUserModuleContent}
-	modules["os_icon"] = ModuleEntry{Get: getOsIconModuleContent}
-	modules["git_status"] = ModuleEntry{Get: getGitStatusModuleContent}
-	modules["exit_status"] = ModuleEntry{Get: getExitStatusModuleContent}
-	modules["git_upstream"] = ModuleEntry{Get: getGitUpstreamModuleContent}
-	modules["git_remote"] = ModuleEntry{Get: getGitRemoteModuleContent}
+	modules.Register(ModuleEntry{
+		Name:        "git_branch",
+		Description: "Displays the current git branch, or the short commit id when HEAD is detached",
+		Providers:   []context.Provider{context.ProviderGit},
+		Example:     "main",
+		Get:         getGitBranchModuleContent,
+	})
+	modules.Register(ModuleEntry{
+		Name:        "hostname",
+		Description: "Displays the hostname of the machine",
+		Providers:   []context.Provider{context.ProviderHostname},
+		Example:     "my-laptop",
+		Get:         getHostnameModuleContent,
+	})
+	modules.Register(ModuleEntry{
+		Name:        "time",
+		Description: "Displays the current time, formatted as HH:MM:SS",
+		Providers:   []context.Provider{context.ProviderTime},
+		Example:     "14:03:27",
+		Get:         getTimeModuleContent,
+	})
+	modules.Register(ModuleEntry{
+		Name:        "cwd",
+		Description: "Displays the current working directory, with the home directory replaced by ~",
+		Options: []ModuleOption{
+			{Name: "cwd.highlight_git_root", Type: "bool", Default: "false", Description: "Underline and bold the git root directory in the path"},
+			{Name: "cwd.highlight_superproject_root", Type: "bool", Default: "false", Description: "Underline and bold the root directory of the superproject when in a submodule"},
+		},
+		// The git provider is only used when one of the highlight options is set
+		Providers: []context.Provider{context.ProviderCWD, context.ProviderHomeDir},
+		Example:   "~/projects/promptorium",
+		Get:       getCwdModuleContent,
+	})
+	modules.Register(ModuleEntry{
+		Name:        "user",
+		Description: "Displays the current user",
+		Providers:   []context.Provider{context.ProviderUser},
+		Example:     "john",
+		Get:         getUserModuleContent,
+	})
+	modules.Register(ModuleEntry{
+		Name:        "os_icon",
+		Description: "Displays the icon of the operating system or Linux distribution",
+		Providers:   []context.Provider{context.ProviderOS},
+		Example:     "",
+		Get:         getOsIconModuleContent,
+	})
+	modules.Register(ModuleEntry{
+		Name:        "os",
+		Description: "Displays the icon, the name and the version of the operating system or Linux distribution",
+		Options: []ModuleOption{
+			{Name: "os.format", Type: "string", Default: DEFAULT_OS_FORMAT, Description: "Format string, with the placeholders {icon} {id} {name} {pretty_name} {version}"},
+		},
+		Providers: []context.Provider{context.ProviderOS},
+		Example:   " Ubuntu 24.04",
+		Get:       getOsModuleContent,
+	})
+	modules.Register(ModuleEntry{
+		Name:        "git_status",
+		Description: "Displays the number of changes in the working tree, stashes and commits ahead of and behind the upstream branch",
+		Options: []ModuleOption{
+			{Name: "git_status.format", Type: "string", Default: DEFAULT_GIT_STATUS_FORMAT, Description: "Format string, with the placeholders {staged} {unstaged} {untracked} {conflicted} {stashed} {ahead} {behind} {clean}"},
+			{Name: "git_status.<placeholder>.symbol", Type: "string", Default: "per placeholder", Description: "Symbol displayed before the count of the placeholder"},
+			{Name: "git_status.<placeholder>.color", Type: "color", Default: "per placeholder", Description: "Color of the placeholder"},
+		},
+		Providers: []context.Provider{context.ProviderGitStatus},
+		Example:   "+1 !2 ?1 ↑3",
+		Get:       getGitStatusModuleContent,
+	})
+	modules.Register(ModuleEntry{
+		Name:        "exit_status",
+		Description: "Displays the exit code of the previous command, or a checkmark if it succeeded",
+		Options: []ModuleOption{
+			{Name: "exit_status.pipeline", Type: "bool", Default: "false", Description: "Display the exit codes of all the commands of a pipeline"},
+			{Name: "exit_status.separator", Type: "string", Default: DEFAULT_EXIT_STATUS_SEPARATOR, Description: "Separator of the exit codes of a pipeline"},
+			{Name: "exit_status.names", Type: "bool", Default: "true", Description: "Display signal names (130 is INT) and the meaning of 126 and 127 instead of the numbers"},
+			{Name: "exit_status.pipefail", Type: "bool", Default: "false", Description: "Count a failure of any command of the pipeline as a failure, also in $exit_code_color"},
+		},
+		Providers: []context.Provider{context.ProviderExitCode},
+		Example:   " ✓ ",
+		Get:       getExitStatusModuleContent,
+	})
+	modules.Register(ModuleEntry{
+		Name:        "cmd_duration",
+		Description: "Displays how long the previous command took, when it took longer than the min_time option",
+		Options: []ModuleOption{
+			{Name: "cmd_duration.min_time", Type: "duration", Default: DEFAULT_CMD_DURATION_MIN_TIME.String(), Description: "Commands faster than this are not displayed"},
+			{Name: "cmd_duration.warn_time", Type: "duration", Default: "0s", Description: "Commands slower than this are displayed with the warning color, 0s to disable"},
+		},
+		Providers: []context.Provider{context.ProviderCmdDuration},
+		Example:   "1m23s",
+		Get:       getCmdDurationModuleContent,
+	})
+	modules.Register(ModuleEntry{
+		Name:        "jobs",
+		Description: "Displays the number of background and stopped jobs of the shell",
+		Options: []ModuleOption{
+			{Name: "jobs.symbol", Type: "string", Default: DEFAULT_JOBS_SYMBOL, Description: "Symbol displayed before the number of jobs"},
+			{Name: "jobs.threshold", Type: "int", Default: "1", Description: "Number of jobs from which the count is displayed, only the symbol is displayed below"},
+			{Name: "jobs.show_zero", Type: "bool", Default: "false", Description: "Display the module when there is no job"},
+		},
+		Providers: []context.Provider{context.ProviderJobs},
+		Example:   DEFAULT_JOBS_SYMBOL + "2",
+		Get:       getJobsModuleContent,
+	})
+	modules.Register(ModuleEntry{
+		Name:        "git_commit",
+		Description: "Displays the nearest tag, the number of commits since that tag and the short commit id of HEAD",
+		Providers:   []context.Provider{context.ProviderGitDescribe},
+		Example:     "v1.4.2+3 (a1b2c3d)",
+		Get:         getGitCommitModuleContent,
+	})
+	modules.Register(ModuleEntry{
+		Name:        "git_state",
+		Description: "Displays the git operation in progress (rebase, merge, cherry-pick, revert, bisect) and a stale index lock",
+		Providers:   []context.Provider{context.ProviderGit},
+		Example:     "REBASE 3/7",
+		Get:         getGitStateModuleContent,
+	})
+	modules.Register(ModuleEntry{
+		Name:        "vcs_branch",
+		Description: "Displays the branch or bookmark in git, Mercurial, Jujutsu and Fossil repositories, or the short commit id when there is none",
+		Providers:   []context.Provider{context.ProviderVCS},
+		Example:     "main",
+		Get:         getVCSBranchModuleContent,
+	})
+	modules.Register(ModuleEntry{
+		Name:        "vcs_status",
+		Description: "Displays the git_status format in git, Mercurial, Jujutsu and Fossil repositories",
+		Options: []ModuleOption{
+			{Name: "git_status.format", Type: "string", Default: DEFAULT_GIT_STATUS_FORMAT, Description: "Format string, shared with the git_status module"},
+		},
+		Providers: []context.Provider{context.ProviderVCSStatus},
+		Example:   "+1 !2 ?1 ↑3",
+		Get:       getVCSStatusModuleContent,
+	})
+	modules.Register(ModuleEntry{
+		Name:        "git_repo",
+		Description: "Displays the linked worktree, the submodule path and whether the repository is bare, shallow or sparse",
+		Providers:   []context.Provider{context.ProviderGit},
+		Example:     "wt:hotfix sub:libs/core shallow",
+		Get:         getGitRepoModuleContent,
+	})
+	modules.Register(ModuleEntry{
+		Name:        "git_upstream",
+		Description: "Displays the upstream branch of the current git branch",
+		Providers:   []context.Provider{context.ProviderGit},
+		Example:     "main",
+		Get:         getGitUpstreamModuleContent,
+	})
+	modules.Register(ModuleEntry{
+		Name:        "git_remote",
+		Description: "Displays the remote of the upstream branch, or the forge and the repository it points to",
+		Options: []ModuleOption{
+			{Name: "git_remote.mode", Type: "string", Default: GIT_REMOTE_MODE_NAME, Description: "name to display the name of the remote, forge to display the forge icon and owner/repo"},
+			{Name: "git_remote.hosts", Type: "map", Default: "", Description: "Forge (github, gitlab, bitbucket, gitea, forgejo) of custom hosts"},
+		},
+		Providers: []context.Provider{context.ProviderGit},
+		Example:   "origin",
+		Get:       getGitRemoteModuleContent,
+	})
 	return modules
 }
 
@@ -38,6 +198,9 @@ func getGitBranchModuleContent(config *Config, component *Component) []Component
 	}
 
 	localBranch := config.Context.GitContext.GetContent().LocalBranch
+	if config.Context.GitContext.GetContent().IsDetachedHead {
+		localBranch = "(" + config.Context.GitContext.GetContent().ShortCommit + ")"
+	}
 	len := utf8.RuneCountInString(localBranch)
 
 	result = append(result, NewComponentContent(component, localBranch, len))
@@ -45,112 +208,194 @@ func getGitBranchModuleContent(config *Config, component *Component) []Component
 	return result
 }
 
-func getHostnameModuleContent(config *Config, component *Component) []ComponentContent {
+func getVCSBranchModuleContent(config *Config, component *Component) []ComponentContent {
 	result := []ComponentContent{}
-	hostname, err := os.Hostname()
-	if err != nil {
+	vcsContext := config.Context.VCSContext.GetContent()
+	if !vcsContext.IsRepo {
 		return result
 	}
-	len := utf8.RuneCountInString(hostname)
-	result = append(result, NewComponentContent(component, hostname, len))
-	return result
-}
 
-func getOsIconModuleContent(config *Config, component *Component) []ComponentContent {
-	result := []ComponentContent{}
-	icon := GetOSIcon(config)
-	len := 1
-	result = append(result, NewComponentContent(component, icon, len))
+	branch := vcsContext.Branch
+	if branch == "" {
+		if vcsContext.Commit == "" {
+			return result
+		}
+		branch = "(" + vcsContext.Commit + ")"
+	}
+	result = append(result, NewComponentContent(component, branch, utf8.RuneCountInString(branch)))
 	return result
 }
 
-func getTimeModuleContent(config *Config, component *Component) []ComponentContent {
-	result := []ComponentContent{}
-	time := time.Now().Format("15:04:05")
-	len := utf8.RuneCountInString(time)
-	result = append(result, NewComponentContent(component, time, len))
-	return result
+var GIT_OPERATION_LABELS = map[gitcontext.GitOperation]string{
+	gitcontext.OperationRebase:     "REBASE",
+	gitcontext.OperationAm:         "AM",
+	gitcontext.OperationMerge:      "MERGE",
+	gitcontext.OperationCherryPick: "CHERRY-PICK",
+	gitcontext.OperationRevert:     "REVERT",
+	gitcontext.OperationBisect:     "BISECT",
 }
 
-func getUserModuleContent(config *Config, component *Component) []ComponentContent {
+func getGitStateModuleContent(config *Config, component *Component) []ComponentContent {
 	result := []ComponentContent{}
-	user := os.Getenv("USER")
-	len := utf8.RuneCountInString(user)
-	result = append(result, NewComponentContent(component, user, len))
+	gitContext := config.Context.GitContext.GetContent()
+	if !gitContext.IsGitRepo {
+		return result
+	}
+
+	states := []string{}
+	if label, ok := GIT_OPERATION_LABELS[gitContext.Operation]; ok {
+		if gitContext.OperationTotal > 0 {
+			label += " " + strconv.Itoa(gitContext.OperationStep) + "/" + strconv.Itoa(gitContext.OperationTotal)
+		}
+		states = append(states, label)
+	}
+	if gitContext.HasStaleIndexLock {
+		states = append(states, "LOCKED")
+	}
+	if len(states) == 0 {
+		return result
+	}
+
+	state := strings.Join(states, " ")
+	result = append(result, NewComponentContent(component, state, utf8.RuneCountInString(state)))
 	return result
 }
 
-func getGitStatusModuleContent(config *Config, component *Component) []ComponentContent {
+func getGitRepoModuleContent(config *Config, component *Component) []ComponentContent {
 	result := []ComponentContent{}
-	if !config.Context.GitContext.GetContent().IsGitRepo {
+	gitContext := config.Context.GitContext.GetContent()
+	if !gitContext.IsGitRepo {
 		return result
 	}
-	var componentLen int
-	gitState := config.Context.GitContext.GetContent()
-	if !gitState.IsGitRepo {
+
+	parts := []string{}
+	if gitContext.IsWorktree {
+		parts = append(parts, "wt:"+gitContext.WorktreeName)
+	}
+	if gitContext.SubmodulePath != "" {
+		parts = append(parts, "sub:"+gitContext.SubmodulePath)
+	}
+	if gitContext.IsBare {
+		parts = append(parts, "bare")
+	}
+	if gitContext.IsShallow {
+		parts = append(parts, "shallow")
+	}
+	if gitContext.IsSparse {
+		parts = append(parts, "sparse")
+	}
+	if len(parts) == 0 {
 		return result
 	}
 
-	gitUpstreamBranch := gitState.UpstreamBranch
-	var gitStatusString string
-	isAheadIndicator := ""
-	isBehindIndicator := ""
-	stagingAreaStatus := ""
-	numberOfChanges := ""
+	repo := strings.Join(parts, " ")
+	result = append(result, NewComponentContent(component, repo, utf8.RuneCountInString(repo)))
+	return result
+}
 
-	if gitState.LocalBranch == "" {
+func getGitCommitModuleContent(config *Config, component *Component) []ComponentContent {
+	result := []ComponentContent{}
+	gitContext := config.Context.GitContext.GetContent()
+	if !gitContext.IsGitRepo || gitContext.ShortCommit == "" {
 		return result
 	}
 
-	// Set staging area status
-	if gitState.UnstagedChanges > 0 || gitState.UntrackedFiles > 0 {
-		stagingAreaStatus += config.ColorizeString("", component.Style.ForegroundColor, component.Style.BackgroundColor)
-		componentLen += 1
-	} else if gitState.StagedChanges > 0 {
-		stagingAreaStatus += config.ColorizeString("", component.Style.ForegroundColor, component.Style.BackgroundColor)
-		componentLen += 1
-	} else {
-		stagingAreaStatus += config.ColorizeString("", config.Theme.SuccessColor, component.Style.BackgroundColor)
-		componentLen += 1
+	commit := "(" + gitContext.ShortCommit + ")"
+	if gitContext.Tag != "" {
+		tag := gitContext.Tag
+		if gitContext.TagDistance > 0 {
+			tag += "+" + strconv.Itoa(gitContext.TagDistance)
+		}
+		commit = tag + " " + commit
 	}
 
-	// Set ahead/behind indicators
-	if gitState.Behind > 0 {
-		isBehindIndicator += config.ColorizeString(" ", config.Theme.ErrorColor, component.Style.BackgroundColor)
-		isBehindIndicator += config.ColorizeString("↓", config.Theme.ErrorColor, component.Style.BackgroundColor)
-		componentLen += 2
+	result = append(result, NewComponentContent(component, commit, utf8.RuneCountInString(commit)))
+	return result
+}
+
+func getHostnameModuleContent(config *Config, component *Component) []ComponentContent {
+	result := []ComponentContent{}
+	hostname := config.Context.Hostname.GetContent()
+	if hostname == "" {
+		return result
 	}
-	if gitState.Ahead > 0 || gitUpstreamBranch == "" {
-		isAheadIndicator += config.ColorizeString(" ", config.Theme.ErrorColor, component.Style.BackgroundColor)
-		isAheadIndicator += config.ColorizeString("↑", config.Theme.GitStatusColorDirty, component.Style.BackgroundColor)
-		componentLen += 2
+	len := utf8.RuneCountInString(hostname)
+	result = append(result, NewComponentContent(component, hostname, len))
+	return result
+}
+
+func getOsIconModuleContent(config *Config, component *Component) []ComponentContent {
+	result := []ComponentContent{}
+	icon := GetOSIcon(config)
+	len := 1
+	result = append(result, NewComponentContent(component, icon, len))
+	return result
+}
+
+func getOsModuleContent(config *Config, component *Component) []ComponentContent {
+	result := []ComponentContent{}
+	osContext := config.Context.OS.GetContent()
+	replacer := strings.NewReplacer(
+		"{icon}", GetOSIcon(config),
+		"{id}", osContext.ID,
+		"{name}", osContext.Name,
+		"{pretty_name}", osContext.PrettyName,
+		"{version}", osContext.VersionID,
+	)
+	// Collapses the spaces around the empty values, e.g. the version of rolling release distributions
+	text := strings.Join(strings.Fields(replacer.Replace(config.Options.OS.Format)), " ")
+	if text == "" {
+		return result
 	}
+	result = append(result, NewComponentContent(component, text, utf8.RuneCountInString(text)))
+	return result
+}
 
-	// Assemble git status string
-	gitStatusString = stagingAreaStatus + numberOfChanges + isAheadIndicator + isBehindIndicator
+func getTimeModuleContent(config *Config, component *Component) []ComponentContent {
+	result := []ComponentContent{}
+	time := config.Context.Time.GetContent().Format("15:04:05")
+	len := utf8.RuneCountInString(time)
+	result = append(result, NewComponentContent(component, time, len))
+	return result
+}
 
-	result = append(result, NewComponentContent(component, gitStatusString, componentLen))
+func getUserModuleContent(config *Config, component *Component) []ComponentContent {
+	result := []ComponentContent{}
+	user := config.Context.User.GetContent()
+	len := utf8.RuneCountInString(user)
+	result = append(result, NewComponentContent(component, user, len))
 	return result
 }
 
 func getCwdModuleContent(config *Config, component *Component) []ComponentContent {
 	result := []ComponentContent{}
 	cwd := config.Context.CWD.GetContent()
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
+	homeDir := config.Context.HomeDir.GetContent()
+	if cwd == "" {
 		return result
 	}
 
 	// Replace home directory with "~"
-	cwd = strings.ReplaceAll(cwd, homeDir, "~")
+	cwd = replaceHomeDir(cwd, homeDir)
 	stringCwd := cwd
 	cwdLen := utf8.RuneCountInString(cwd)
 	componentContent := NewComponentContent(component, stringCwd, cwdLen)
 	result = append(result, componentContent)
 
-	if config.Options.CWD.HighlightGitRoot && config.Context.GitContext.GetContent().IsGitRepo {
+	options := config.Options.CWD
+	gitContext := config.Context.GitContext.GetContent()
+	if (options.HighlightGitRoot || options.HighlightSuperprojectRoot) && gitContext.IsGitRepo {
 		result = []ComponentContent{}
-		gitRoot := strings.Split(strings.ReplaceAll(config.Context.GitContext.GetContent().GitRoot(), homeDir, "~"), "/")
+		// Indexes of the highlighted directories in the path
+		highlighted := map[int]bool{}
+		if options.HighlightGitRoot {
+			gitRoot := strings.Split(replaceHomeDir(gitContext.GitRoot(), homeDir), "/")
+			highlighted[len(gitRoot)-1] = true
+		}
+		if options.HighlightSuperprojectRoot && gitContext.SuperprojectRoot != "" {
+			superprojectRoot := strings.Split(replaceHomeDir(gitContext.SuperprojectRoot, homeDir), "/")
+			highlighted[len(superprojectRoot)-1] = true
+		}
 
 		for i, part := range strings.Split(cwd, "/") {
 			partLen := utf8.RuneCountInString(part)
@@ -159,7 +404,7 @@ func getCwdModuleContent(config *Config, component *Component) []ComponentConten
 				result = append(result, NewComponentContent(component, "/", 1))
 			}
 			resultPart := NewComponentContent(component, partStr, partLen)
-			if i == len(gitRoot)-1 {
+			if highlighted[i] {
 				resultPart.Bold = true
 				resultPart.Underline = true
 			}
@@ -170,12 +415,42 @@ func getCwdModuleContent(config *Config, component *Component) []ComponentConten
 	return result
 }
 
+// Replaces the home directory with "~" in the path, unless the home directory is unknown
+func replaceHomeDir(path string, homeDir string) string {
+	if homeDir == "" {
+		return path
+	}
+	return strings.ReplaceAll(path, homeDir, "~")
+}
+
 func getExitStatusModuleContent(config *Config, component *Component) []ComponentContent {
 	result := []ComponentContent{}
-	// if exit code is 0, return a checkmark
+	options := config.Options.ExitStatus
+	exitCode := config.Context.ExitCode.GetContent()
+	pipeStatus := config.Context.PipeStatus.GetContent()
+
+	if options.Pipeline && len(pipeStatus) > 1 {
+		codes := []string{}
+		for _, code := range pipeStatus {
+			codes = append(codes, formatExitCode(code, options.Names))
+		}
+		status := strings.Join(codes, options.Separator)
+		result = append(result, NewComponentContent(component, status, utf8.RuneCountInString(status)))
+		return result
+	}
 
-	status := strconv.Itoa(config.Context.ExitCode.GetContent())
-	if config.Context.ExitCode.GetContent() == 0 {
+	if options.Pipefail {
+		// The exit code of the last command which failed, as with "set -o pipefail"
+		for _, code := range pipeStatus {
+			if code != 0 {
+				exitCode = code
+			}
+		}
+	}
+
+	// if exit code is 0, return a checkmark
+	status := formatExitCode(exitCode, options.Names)
+	if exitCode == 0 {
 		status = "✓"
 	}
 
@@ -194,6 +469,80 @@ func getExitStatusModuleContent(config *Config, component *Component) []Componen
 	return result
 }
 
+// Meaning of the exit codes set by the shell
+var EXIT_CODE_NAMES = map[int]string{
+	126: "NOEXEC",
+	127: "NOTFOUND",
+}
+
+// Returns the exit code, or with names its meaning or the name of the signal which killed the command (130 is INT)
+func formatExitCode(code int, names bool) string {
+	if !names {
+		return strconv.Itoa(code)
+	}
+	if name, ok := EXIT_CODE_NAMES[code]; ok {
+		return name
+	}
+	// The shells report a command killed by a signal with 128 + the signal number
+	if code > 128 && code < 128+65 {
+		if name := unix.SignalName(syscall.Signal(code - 128)); name != "" {
+			return strings.TrimPrefix(name, "SIG")
+		}
+	}
+	return strconv.Itoa(code)
+}
+
+func getCmdDurationModuleContent(config *Config, component *Component) []ComponentContent {
+	result := []ComponentContent{}
+	duration := config.Context.CmdDuration.GetContent()
+	options := config.Options.CmdDuration
+	if duration == 0 || duration < options.MinTime {
+		return result
+	}
+
+	text := formatDuration(duration)
+	content := NewComponentContent(component, text, utf8.RuneCountInString(text))
+	if options.WarnTime > 0 && duration >= options.WarnTime {
+		content.ForegroundColor = config.Theme.WarningColor
+	}
+	result = append(result, content)
+	return result
+}
+
+// Formats a duration as 850ms, 12s, 1m23s or 2h0m5s
+func formatDuration(duration time.Duration) string {
+	if duration < time.Second {
+		return strconv.FormatInt(duration.Milliseconds(), 10) + "ms"
+	}
+	seconds := int(duration / time.Second)
+	hours, minutes := seconds/3600, seconds/60%60
+	seconds %= 60
+	switch {
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm%ds", hours, minutes, seconds)
+	case minutes > 0:
+		return fmt.Sprintf("%dm%ds", minutes, seconds)
+	default:
+		return fmt.Sprintf("%ds", seconds)
+	}
+}
+
+func getJobsModuleContent(config *Config, component *Component) []ComponentContent {
+	result := []ComponentContent{}
+	jobs := config.Context.Jobs.GetContent()
+	options := config.Options.Jobs
+	if jobs == 0 && !options.ShowZero {
+		return result
+	}
+
+	text := options.Symbol
+	if jobs >= options.Threshold || jobs == 0 {
+		text += strconv.Itoa(jobs)
+	}
+	result = append(result, NewComponentContent(component, text, utf8.RuneCountInString(text)))
+	return result
+}
+
 func getGitUpstreamModuleContent(config *Config, component *Component) []ComponentContent {
 	result := []ComponentContent{}
 	gitContext := config.Context.GitContext.GetContent()
@@ -209,7 +558,18 @@ func getGitUpstreamModuleContent(config *Config, component *Component) []Compone
 func getGitRemoteModuleContent(config *Config, component *Component) []ComponentContent {
 	result := []ComponentContent{}
 	gitContext := config.Context.GitContext.GetContent()
-	if !gitContext.IsGitRepo || !gitContext.HasUpstream {
+	if !gitContext.IsGitRepo {
+		return result
+	}
+
+	repository := gitContext.RemoteRepository
+	if config.Options.GitRemote.Mode == GIT_REMOTE_MODE_FORGE && repository.Host != "" {
+		remote := GetForgeIcon(config, repository.Host) + " " + repository.Owner + "/" + repository.Repo
+		result = append(result, NewComponentContent(component, remote, utf8.RuneCountInString(remote)))
+		return result
+	}
+
+	if !gitContext.HasUpstream {
 		return result
 	}
 	remote := gitContext.Remote
@@ -2,12 +2,13 @@ package config
 
 import (
 	"fmt"
-	"os"
+	"promptorium/internal/log"
 	"promptorium/internal/pkg/confpkg/context"
+	"promptorium/internal/pkg/confpkg/context/gitcontext"
+	"promptorium/internal/pkg/confpkg/context/vcscontext"
 	"strconv"
 	"strings"
-
-	"github.com/rs/zerolog/log"
+	"time"
 )
 
 var SPACER_PROMPT_ELEMENT = "---"
@@ -17,21 +18,41 @@ var SPACER_PROMPT_ELEMENT = "---"
  */
 
 func ParseConfig(rawConfig RawConfig) (Config, error) {
+	return ParseConfigWithModules(rawConfig, loadModules())
+}
+
+// ParseConfigWithModules parses the raw config, using the modules of the given registry instead of the built-in ones
+func ParseConfigWithModules(rawConfig RawConfig, modules ModuleRegistry) (Config, error) {
 	conf := Config{}
 	conf.Version = rawConfig.Version
 	conf.Context = rawConfig.Context
 
-	conf.Modules = loadModules()
+	conf.Modules = modules
+
+	// Options are parsed first, because the timeouts must be set before reading the context
+	conf.Options = parseOptions(rawConfig.Options, rawConfig.Context)
+	applyTimeouts(conf.Options.Timeouts, rawConfig.Context)
+	if conf.Options.Git.Cache && rawConfig.Context != nil {
+		rawConfig.Context.SetGitCache(conf.Options.Git.CacheMaxAge)
+	}
+	if rawConfig.Context != nil {
+		rawConfig.Context.SetPipefail(conf.Options.ExitStatus.Pipefail)
+	}
+
+	// Only the components used in the prompt are parsed, so that the providers used by the other components are not started
+	rawComponents := getPromptRawComponents(rawConfig.Prompt, rawConfig.Components)
+	if rawConfig.Context != nil {
+		rawConfig.Context.Start(getRequiredProviders(rawConfig.Prompt, rawComponents, conf.Options, modules)...)
+	}
 
-	conf.Theme = parseTheme(rawConfig.Theme)
-	conf.Components = parseComponents(rawConfig.Components, conf.Theme, rawConfig.Context)
+	conf.Theme = parseTheme(rawConfig.Theme, rawConfig.Context)
+	conf.Components = parseComponents(rawComponents, conf.Theme, rawConfig.Context)
 	conf.Prompt = parsePrompt(rawConfig.Prompt, conf.Theme, rawConfig.Context, conf.Components, conf.Modules)
-	conf.Options = parseOptions(rawConfig.Options)
 	return conf, nil
 }
 
 // Parses the theme from the raw theme
-func parseTheme(theme RawTheme) Theme {
+func parseTheme(theme RawTheme, context *context.ApplicationContext) Theme {
 	var resultTheme Theme
 	defaultTheme := getDefaultTheme()
 
@@ -40,21 +61,29 @@ func parseTheme(theme RawTheme) Theme {
 	resultTheme.Spacer = theme.Spacer
 
 	// Colors
-	resultTheme.PrimaryColor = parseBaseColor(theme.PrimaryColor, "primary", defaultTheme.PrimaryColor)
-	resultTheme.SecondaryColor = parseBaseColor(theme.SecondaryColor, "secondary", defaultTheme.SecondaryColor)
-	resultTheme.TertiaryColor = parseBaseColor(theme.TertiaryColor, "tertiary", defaultTheme.TertiaryColor)
-	resultTheme.QuaternaryColor = parseBaseColor(theme.QuaternaryColor, "quaternary", defaultTheme.QuaternaryColor)
-	resultTheme.SuccessColor = parseBaseColor(theme.SuccessColor, "success", defaultTheme.SuccessColor)
-	resultTheme.WarningColor = parseBaseColor(theme.WarningColor, "warning", defaultTheme.WarningColor)
-	resultTheme.ErrorColor = parseBaseColor(theme.ErrorColor, "error", defaultTheme.ErrorColor)
-	resultTheme.BackgroundColor = parseBaseColor(theme.BackgroundColor, "background", defaultTheme.BackgroundColor)
-	resultTheme.ForegroundColor = parseBaseColor(theme.ForegroundColor, "foreground", defaultTheme.ForegroundColor)
-	resultTheme.GitStatusColorClean = parseBaseColor(theme.GitStatusColorClean, "git_status_clean", defaultTheme.GitStatusColorClean)
-	resultTheme.GitStatusColorDirty = parseBaseColor(theme.GitStatusColorDirty, "git_status_dirty", defaultTheme.GitStatusColorDirty)
-	resultTheme.GitStatusColorNoRepository = parseBaseColor(theme.GitStatusColorNoRepository, "git_status_no_repository", defaultTheme.GitStatusColorNoRepository)
-	resultTheme.GitStatusColorNoUpstream = parseBaseColor(theme.GitStatusColorNoUpstream, "git_status_no_upstream", defaultTheme.GitStatusColorNoUpstream)
-	resultTheme.ExitCodeColorOk = parseBaseColor(theme.ExitCodeColorOk, "exit_code_ok", defaultTheme.ExitCodeColorOk)
-	resultTheme.ExitCodeColorError = parseBaseColor(theme.ExitCodeColorError, "exit_code_error", defaultTheme.ExitCodeColorError)
+	resultTheme.PrimaryColor = parseBaseColor(theme.PrimaryColor, "primary", defaultTheme.PrimaryColor, context)
+	resultTheme.SecondaryColor = parseBaseColor(theme.SecondaryColor, "secondary", defaultTheme.SecondaryColor, context)
+	resultTheme.TertiaryColor = parseBaseColor(theme.TertiaryColor, "tertiary", defaultTheme.TertiaryColor, context)
+	resultTheme.QuaternaryColor = parseBaseColor(theme.QuaternaryColor, "quaternary", defaultTheme.QuaternaryColor, context)
+	resultTheme.SuccessColor = parseBaseColor(theme.SuccessColor, "success", defaultTheme.SuccessColor, context)
+	resultTheme.WarningColor = parseBaseColor(theme.WarningColor, "warning", defaultTheme.WarningColor, context)
+	resultTheme.ErrorColor = parseBaseColor(theme.ErrorColor, "error", defaultTheme.ErrorColor, context)
+	resultTheme.BackgroundColor = parseBaseColor(theme.BackgroundColor, "background", defaultTheme.BackgroundColor, context)
+	resultTheme.ForegroundColor = parseBaseColor(theme.ForegroundColor, "foreground", defaultTheme.ForegroundColor, context)
+	resultTheme.GitStatusColorClean = parseBaseColor(theme.GitStatusColorClean, "git_status_clean", defaultTheme.GitStatusColorClean, context)
+	resultTheme.GitStatusColorDirty = parseBaseColor(theme.GitStatusColorDirty, "git_status_dirty", defaultTheme.GitStatusColorDirty, context)
+	resultTheme.GitStatusColorNoRepository = parseBaseColor(theme.GitStatusColorNoRepository, "git_status_no_repository", defaultTheme.GitStatusColorNoRepository, context)
+	resultTheme.GitStatusColorNoUpstream = parseBaseColor(theme.GitStatusColorNoUpstream, "git_status_no_upstream", defaultTheme.GitStatusColorNoUpstream, context)
+	resultTheme.ExitCodeColorOk = parseBaseColor(theme.ExitCodeColorOk, "exit_code_ok", defaultTheme.ExitCodeColorOk, context)
+	resultTheme.ExitCodeColorError = parseBaseColor(theme.ExitCodeColorError, "exit_code_error", defaultTheme.ExitCodeColorError, context)
+	resultTheme.GitStateColorClean = parseBaseColor(theme.GitStateColorClean, "git_state_clean", defaultTheme.GitStateColorClean, context)
+	resultTheme.GitStateColorInProgress = parseBaseColor(theme.GitStateColorInProgress, "git_state_in_progress", defaultTheme.GitStateColorInProgress, context)
+	resultTheme.GitStateColorLocked = parseBaseColor(theme.GitStateColorLocked, "git_state_locked", defaultTheme.GitStateColorLocked, context)
+
+	resultTheme.OSIcons = map[string]string{}
+	for id, icon := range theme.OSIcons {
+		resultTheme.OSIcons[strings.ToLower(id)] = icon
+	}
 	return resultTheme
 }
 
@@ -78,10 +107,11 @@ func parseComponents(components []RawComponent, theme Theme, context *context.Ap
 		resultComponent.Content = component.Content
 		resultComponent.Icon = string(component.Style.Icon)
 		resultComponent.Type = parseComponentType(component.Type, theme, context)
+		resultComponent.Timeout = parseTimeout(component.Timeout, "component", DEFAULT_COMMAND_TIMEOUT, context)
 
 		// Return an error if a component with the same name already exists
 		if _, ok := resultComponents[resultComponent.Name]; ok {
-			fmt.Println("promptorium: error: component " + component.Name + " already exists")
+			context.ReportProblem(fmt.Errorf("component %s already exists", component.Name))
 		}
 
 		resultComponents[resultComponent.Name] = resultComponent
@@ -90,6 +120,87 @@ func parseComponents(components []RawComponent, theme Theme, context *context.Ap
 	return resultComponents
 }
 
+// Returns the raw components referenced in the prompt
+func getPromptRawComponents(prompt [][]string, components []RawComponent) []RawComponent {
+	promptElements := map[string]bool{}
+	for _, promptLine := range prompt {
+		for _, promptElement := range promptLine {
+			promptElements[strings.Trim(promptElement, " ")] = true
+		}
+	}
+
+	result := []RawComponent{}
+	for _, component := range components {
+		if promptElements["$"+component.Name] {
+			result = append(result, component)
+		}
+	}
+	return result
+}
+
+// Returns the context providers needed to render the prompt, based on the modules and color functions used by its components
+func getRequiredProviders(prompt [][]string, components []RawComponent, options ConfigOptions, modules ModuleRegistry) []context.Provider {
+	result := []context.Provider{context.ProviderShell}
+
+	rawComponents := map[string]RawComponent{}
+	for _, component := range components {
+		rawComponents["$"+component.Name] = component
+	}
+	defaultComponents := getDefaultComponents()
+
+	for _, promptLine := range prompt {
+		for _, promptElement := range promptLine {
+			promptElement = strings.Trim(promptElement, " ")
+
+			if promptElement == SPACER_PROMPT_ELEMENT {
+				result = append(result, context.ProviderTerminalWidth)
+				continue
+			}
+
+			var componentType, content string
+			if component, ok := rawComponents[promptElement]; ok {
+				componentType, content = strings.ToLower(string(component.Type)), component.Content
+				result = append(result, getColorProviders(component.Style.BackgroundColor, component.Style.ForegroundColor, component.Style.IconBackgroundColor, component.Style.IconForegroundColor)...)
+			} else if component, ok := defaultComponents[promptElement]; ok {
+				componentType, content = string(component.Type), component.Content
+			}
+
+			switch componentType {
+			case "module":
+				if module, ok := modules[content]; ok {
+					result = append(result, module.Providers...)
+				}
+				if content == "cwd" && (options.CWD.HighlightGitRoot || options.CWD.HighlightSuperprojectRoot) {
+					result = append(result, context.ProviderGit)
+				}
+			case "plugin":
+				result = append(result, PLUGIN_PROVIDERS...)
+			}
+		}
+	}
+
+	log.Trace().Msgf("Required context providers: %v", result)
+	return result
+}
+
+// Returns the providers used by the color functions among the given colors
+func getColorProviders(colors ...RawColorName) []context.Provider {
+	result := []context.Provider{}
+	for _, color := range colors {
+		switch color {
+		case "$git_status_color":
+			result = append(result, context.ProviderGitStatus)
+		case "$git_state_color":
+			result = append(result, context.ProviderGit)
+		case "$vcs_status_color":
+			result = append(result, context.ProviderVCSStatus)
+		case "$exit_code_color":
+			result = append(result, context.ProviderExitCode)
+		}
+	}
+	return result
+}
+
 func getDefaultComponents() map[string]Component {
 
 	return map[string]Component{
@@ -165,7 +276,7 @@ func getDefaultComponents() map[string]Component {
 }
 
 // / Parses and validates the prompt array. "$" at the beginning of the prompt elements are here to indicate that the prompt element is a component
-func parsePrompt(prompt [][]string, theme Theme, context *context.ApplicationContext, components map[string]Component, modules map[string]ModuleEntry) [][]string {
+func parsePrompt(prompt [][]string, theme Theme, context *context.ApplicationContext, components map[string]Component, modules ModuleRegistry) [][]string {
 	log.Trace().Msgf("Parsing prompt: %v", prompt)
 	resultPrompt := [][]string{}
 	for _, promptLine := range prompt {
@@ -178,7 +289,7 @@ func parsePrompt(prompt [][]string, theme Theme, context *context.ApplicationCon
 			if ok {
 				resultPromptLine = append(resultPromptLine, trimmedPromptElement)
 			} else {
-				fmt.Fprintf(os.Stderr, "promptorium: component %v not found \n", trimmedPromptElement)
+				context.ReportProblem(fmt.Errorf("component %v not found", trimmedPromptElement))
 			}
 
 		}
@@ -190,16 +301,103 @@ func parsePrompt(prompt [][]string, theme Theme, context *context.ApplicationCon
 	return resultPrompt
 }
 
-func parseOptions(options RawOptions) ConfigOptions {
+func parseOptions(options RawOptions, context *context.ApplicationContext) ConfigOptions {
 	// TODO: Improve this
 	log.Trace().Msgf("Parsing options: %v", options)
 	resultOptions := ConfigOptions{}
 
 	resultOptions.CWD.HighlightGitRoot = options.CWD.HighlightGitRoot
+	resultOptions.CWD.HighlightSuperprojectRoot = options.CWD.HighlightSuperprojectRoot
+	resultOptions.Git.Cache = options.Git.Cache
+	resultOptions.Git.CacheMaxAge = parseTimeout(options.Git.CacheMaxAge, "git cache max age", DEFAULT_GIT_CACHE_MAX_AGE, context)
+	resultOptions.GitStatus = parseGitStatusOptions(options.GitStatus)
+	resultOptions.GitRemote = parseGitRemoteOptions(options.GitRemote, context)
+	resultOptions.CmdDuration.MinTime = parseTimeout(options.CmdDuration.MinTime, "cmd_duration min_time", DEFAULT_CMD_DURATION_MIN_TIME, context)
+	resultOptions.CmdDuration.WarnTime = parseTimeout(options.CmdDuration.WarnTime, "cmd_duration warn_time", 0, context)
+	resultOptions.ExitStatus = ExitStatusOptions{
+		Pipeline:  options.ExitStatus.Pipeline,
+		Separator: DEFAULT_EXIT_STATUS_SEPARATOR,
+		Names:     true,
+		Pipefail:  options.ExitStatus.Pipefail,
+	}
+	if options.ExitStatus.Separator != nil {
+		resultOptions.ExitStatus.Separator = *options.ExitStatus.Separator
+	}
+	if options.ExitStatus.Names != nil {
+		resultOptions.ExitStatus.Names = *options.ExitStatus.Names
+	}
+	resultOptions.Jobs = JobsOptions{Symbol: DEFAULT_JOBS_SYMBOL, Threshold: options.Jobs.Threshold, ShowZero: options.Jobs.ShowZero}
+	if options.Jobs.Symbol != nil {
+		resultOptions.Jobs.Symbol = *options.Jobs.Symbol
+	}
+	if resultOptions.Jobs.Threshold <= 0 {
+		resultOptions.Jobs.Threshold = 1
+	}
+	resultOptions.OS.Format = options.OS.Format
+	if resultOptions.OS.Format == "" {
+		resultOptions.OS.Format = DEFAULT_OS_FORMAT
+	}
+	resultOptions.Timeouts = parseTimeoutOptions(options.Timeouts, context)
+
+	return resultOptions
+}
+
+func parseTimeoutOptions(options RawTimeoutOptions, appContext *context.ApplicationContext) TimeoutOptions {
+	resultOptions := TimeoutOptions{
+		Prompt:    DEFAULT_PROMPT_TIMEOUT,
+		Providers: map[context.Provider]time.Duration{},
+		Marker:    DEFAULT_TIMEOUT_MARKER,
+	}
+
+	if options.Prompt != "" {
+		resultOptions.Prompt = parseTimeout(options.Prompt, "prompt", DEFAULT_PROMPT_TIMEOUT, appContext)
+	}
+	for provider, rawTimeout := range options.Providers {
+		resultOptions.Providers[context.Provider(provider)] = parseTimeout(rawTimeout, provider, 0, appContext)
+	}
+	if options.Marker != nil {
+		resultOptions.Marker = *options.Marker
+	}
 
 	return resultOptions
 }
 
+func parseGitRemoteOptions(options RawGitRemoteOptions, context *context.ApplicationContext) GitRemoteOptions {
+	result := GitRemoteOptions{Mode: strings.ToLower(options.Mode), Hosts: map[string]string{}}
+	switch result.Mode {
+	case "":
+		result.Mode = GIT_REMOTE_MODE_NAME
+	case GIT_REMOTE_MODE_NAME, GIT_REMOTE_MODE_FORGE:
+	default:
+		context.ReportProblem(fmt.Errorf("unknown git_remote mode %s, using %s instead", options.Mode, GIT_REMOTE_MODE_NAME))
+		result.Mode = GIT_REMOTE_MODE_NAME
+	}
+	for host, forge := range options.Hosts {
+		forge = strings.ToLower(forge)
+		if _, ok := FORGE_ICONS[forge]; !ok {
+			context.ReportProblem(fmt.Errorf("unknown forge %s for host %s", forge, host))
+			continue
+		}
+		result.Hosts[strings.ToLower(host)] = forge
+	}
+	return result
+}
+
+// Sets the prompt deadline and the provider timeouts on the context
+func applyTimeouts(options TimeoutOptions, context *context.ApplicationContext) {
+	if context == nil {
+		return
+	}
+	if options.Prompt > 0 {
+		context.SetDeadline(context.CreatedAt.Add(options.Prompt))
+	}
+	for provider, timeout := range options.Providers {
+		if timeout > 0 {
+			context.SetTimeout(provider, timeout)
+		}
+	}
+}
+
 func parseComponentStyle(componentStyle RawComponentStyle, theme Theme, context *context.ApplicationContext) ComponentStyle {
 	resultComponentStyle := ComponentStyle{}
 
@@ -208,7 +406,7 @@ func parseComponentStyle(componentStyle RawComponentStyle, theme Theme, context
 	resultComponentStyle.MarginLeft, resultComponentStyle.MarginRight = parseMargin(componentStyle.Margin)
 	resultComponentStyle.PaddingLeft, resultComponentStyle.PaddingRight = parsePadding(componentStyle.Padding)
 	resultComponentStyle.IconPosition = IconPosition(componentStyle.IconPosition)
-	resultComponentStyle.IconPadding = parseIconPadding(componentStyle.IconPadding)
+	resultComponentStyle.IconPadding = parseIconPadding(componentStyle.IconPadding, context)
 	resultComponentStyle.IconSeparator = componentStyle.IconSeparator
 	resultComponentStyle.IconForegroundColor = parseColor(componentStyle.IconForegroundColor, theme, "icon foreground", resultComponentStyle.ForegroundColor, context)
 	resultComponentStyle.IconBackgroundColor = parseColor(componentStyle.IconBackgroundColor, theme, "icon background", resultComponentStyle.BackgroundColor, context)
@@ -274,19 +472,36 @@ func parsePadding(rawPadding string) (int, int) {
 	return paddingLeft, paddingRight
 }
 
-func parseIconPadding(rawPadding string) int {
+func parseIconPadding(rawPadding string, context *context.ApplicationContext) int {
 	padding := 0
 	if rawPadding == "" {
 		return padding
 	}
 	padding, err := strconv.Atoi(rawPadding)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "promptorium: Error parsing icon padding", rawPadding, ", using default padding instead", "(", padding, ")")
+		context.ReportProblem(fmt.Errorf("error parsing icon padding %s, using default padding instead (%d)", rawPadding, padding))
 		return padding
 	}
 	return padding
 }
 
+// Parses a timeout. It can be a duration ("200ms", "1s") or a number of milliseconds
+func parseTimeout(rawTimeout string, timeoutName string, defaultTimeout time.Duration, context *context.ApplicationContext) time.Duration {
+	if rawTimeout == "" {
+		return defaultTimeout
+	}
+	timeout, err := time.ParseDuration(rawTimeout)
+	if err == nil {
+		return timeout
+	}
+	milliseconds, err := time.ParseDuration(rawTimeout + "ms")
+	if err == nil {
+		return milliseconds
+	}
+	context.ReportProblem(fmt.Errorf("error parsing %s timeout %s, using default timeout instead (%s)", timeoutName, rawTimeout, defaultTimeout))
+	return defaultTimeout
+}
+
 func parseMargin(rawMargin string) (int, int) {
 	marginLeft := 0
 	marginRight := 0
@@ -344,17 +559,21 @@ func parseColor(rawColor RawColorName, theme Theme, colorName string, defaultCol
 		color = theme.ErrorColor
 	case "$git_status_color":
 		color = getGitStatusColor(theme, context)
+	case "$git_state_color":
+		color = getGitStateColor(theme, context)
+	case "$vcs_status_color":
+		color = getVCSStatusColor(theme, context)
 	case "$exit_code_color":
 		color = getExitCodeColor(theme, context)
 	default:
-		fmt.Fprintln(os.Stderr, "promptorium: Error parsing color", rawColor, ", using default", colorName, `color instead`, "(", defaultColor.Name, ")")
+		context.ReportProblem(fmt.Errorf("error parsing color %s, using default %s color instead (%s)", rawColor, colorName, defaultColor.Name))
 		color = defaultColor
 	}
 	return color
 }
 
 // Parses the color from the raw color name and sets the corresponding values in the Color struct
-func parseBaseColor(rawColor RawColorName, colorName string, defaultColor Color) Color {
+func parseBaseColor(rawColor RawColorName, colorName string, defaultColor Color, context *context.ApplicationContext) Color {
 
 	if rawColor == "" {
 		return defaultColor
@@ -364,7 +583,7 @@ func parseBaseColor(rawColor RawColorName, colorName string, defaultColor Color)
 	}
 	color, ok := Colors[string(rawColor)]
 	if !ok {
-		fmt.Fprintln(os.Stderr, "promptorium: Error parsing color", rawColor, ", using default", colorName, `color instead`, "(", defaultColor.Name, ")")
+		context.ReportProblem(fmt.Errorf("error parsing color %s, using default %s color instead (%s)", rawColor, colorName, defaultColor.Name))
 		return defaultColor
 	}
 	return color
@@ -397,8 +616,47 @@ func getGitStatusColor(theme Theme, context *context.ApplicationContext) Color {
 
 }
 
+// Same as the git status color, in the repositories of all the version control systems
+func getVCSStatusColor(theme Theme, context *context.ApplicationContext) Color {
+	vcsContext := context.VCSContext.GetContent()
+
+	if !vcsContext.IsRepo {
+		log.Trace().Msg("Setting vcs status color to no repository")
+		return theme.GitStatusColorNoRepository
+	}
+	// Mercurial and Fossil can't know the upstream without contacting the remote repository
+	supportsUpstream := vcsContext.VCS == vcscontext.VCSGit || vcsContext.VCS == vcscontext.VCSJujutsu
+	if !vcsContext.HasUpstream && supportsUpstream {
+		log.Trace().Msg("Setting vcs status color to no upstream branch")
+		return theme.GitStatusColorNoUpstream
+	}
+	if vcsContext.IsDirty {
+		log.Trace().Msg("Setting vcs status color to dirty")
+		return theme.GitStatusColorDirty
+	}
+
+	log.Trace().Msg("Setting vcs status color to clean")
+	return theme.GitStatusColorClean
+}
+
+func getGitStateColor(theme Theme, context *context.ApplicationContext) Color {
+	gitState := context.GitContext.GetContent()
+
+	if gitState.HasStaleIndexLock {
+		log.Trace().Msg("Setting git state color to locked")
+		return theme.GitStateColorLocked
+	}
+	if gitState.Operation != gitcontext.OperationNone {
+		log.Trace().Msg("Setting git state color to in progress")
+		return theme.GitStateColorInProgress
+	}
+
+	log.Trace().Msg("Setting git state color to clean")
+	return theme.GitStateColorClean
+}
+
 func getExitCodeColor(theme Theme, context *context.ApplicationContext) Color {
-	if context.ExitCode.GetContent() == 0 {
+	if !context.Failed() {
 		return theme.ExitCodeColorOk
 	}
 	return theme.ExitCodeColorError
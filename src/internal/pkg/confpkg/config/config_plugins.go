@@ -0,0 +1,125 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"promptorium/internal/log"
+	"promptorium/internal/pkg/confpkg/context"
+	"promptorium/internal/pkg/confpkg/context/gitcontext"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var PLUGIN_TIMEOUT = 500 * time.Millisecond
+
+// Context providers used to build the plugin input
+var PLUGIN_PROVIDERS = []context.Provider{
+	context.ProviderCWD,
+	context.ProviderExitCode,
+	context.ProviderShell,
+	context.ProviderTerminalWidth,
+	context.ProviderGitStatus,
+}
+
+// PluginInput is the JSON document written to the plugin's stdin
+type PluginInput struct {
+	Version       string                `json:"version"`
+	Component     string                `json:"component"`
+	CWD           string                `json:"cwd"`
+	ExitCode      int                   `json:"exit_code"`
+	Shell         string                `json:"shell"`
+	TerminalWidth int                   `json:"terminal_width"`
+	Git           gitcontext.GitContext `json:"git"`
+}
+
+// PluginSegment is a single styled segment printed by the plugin on stdout.
+// Colors accept the same values as the component style (e.g. "red", "$primary_color", "$exit_code_color")
+type PluginSegment struct {
+	Text            string       `json:"text"`
+	ForegroundColor RawColorName `json:"foreground_color,omitempty"`
+	BackgroundColor RawColorName `json:"background_color,omitempty"`
+	Bold            bool         `json:"bold,omitempty"`
+	Underline       bool         `json:"underline,omitempty"`
+}
+
+// GetPluginContent runs the plugin named in the component content and converts its output to ComponentContent.
+// If the plugin can't be run or its output can't be parsed, the component is not displayed.
+func GetPluginContent(config *Config, component *Component) []ComponentContent {
+	result := []ComponentContent{}
+
+	pluginPath, err := getPluginPath(component.Content)
+	if err != nil {
+		config.Context.ReportProblem(fmt.Errorf("plugin %s not found: %w", component.Content, err))
+		return result
+	}
+
+	input, err := json.Marshal(getPluginInput(config, component))
+	if err != nil {
+		log.Warn().Msgf("Error encoding input for plugin %s: %s", component.Content, err)
+		return result
+	}
+
+	output, err := runPlugin(pluginPath, input)
+	if err != nil {
+		config.Context.ReportProblem(fmt.Errorf("plugin %s failed: %w", component.Content, err))
+		return result
+	}
+
+	segments := []PluginSegment{}
+	err = json.Unmarshal(output, &segments)
+	if err != nil {
+		config.Context.ReportProblem(fmt.Errorf("could not parse output of plugin %s: %w", component.Content, err))
+		return result
+	}
+
+	for _, segment := range segments {
+		content := NewComponentContent(component, segment.Text, utf8.RuneCountInString(segment.Text))
+		content.ForegroundColor = parseColor(segment.ForegroundColor, config.Theme, "plugin foreground", component.Style.ForegroundColor, config.Context)
+		content.BackgroundColor = parseColor(segment.BackgroundColor, config.Theme, "plugin background", component.Style.BackgroundColor, config.Context)
+		content.Bold = segment.Bold
+		content.Underline = segment.Underline
+		result = append(result, content)
+	}
+
+	return result
+}
+
+func getPluginInput(config *Config, component *Component) PluginInput {
+	return PluginInput{
+		Version:       config.Version,
+		Component:     strings.TrimPrefix(component.Name, "$"),
+		CWD:           config.Context.CWD.GetContent(),
+		ExitCode:      config.Context.ExitCode.GetContent(),
+		Shell:         config.Context.Shell.GetContent().String(),
+		TerminalWidth: config.Context.TerminalWidth.GetContent(),
+		Git:           config.Context.GitContext.GetContent(),
+	}
+}
+
+// Plugins are looked up in the plugin directory, unless an absolute path is given
+func getPluginPath(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("empty plugin name")
+	}
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(DEFAULT_PLUGIN_PATH, name)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() || info.Mode()&0111 == 0 {
+		return "", fmt.Errorf("%s is not executable", path)
+	}
+	return path, nil
+}
+
+func runPlugin(path string, input []byte) ([]byte, error) {
+	log.Trace().Msgf("Running plugin %s", path)
+	return runWithTimeout(PLUGIN_TIMEOUT, input, path)
+}
@@ -2,7 +2,6 @@ package config
 
 import (
 	"promptorium/internal/pkg/confpkg/context"
-	"promptorium/internal/pkg/confpkg/context/oscontext"
 	"strings"
 	"unicode/utf8"
 )
@@ -40,24 +39,119 @@ func (c *Config) GetSpacer(promptLen int, terminalWidth int) string {
 	return c.ColorizeString(spacer, c.Theme.ForegroundColor, c.Theme.BackgroundColor)
 }
 
+// GetTimeoutMarkerContent returns the marker shown in components whose context providers did not return in time
+func GetTimeoutMarkerContent(config *Config, component *Component) []ComponentContent {
+	marker := config.Options.Timeouts.Marker
+	if marker == "" {
+		return []ComponentContent{}
+	}
+	content := NewComponentContent(component, marker, utf8.RuneCountInString(marker))
+	content.ForegroundColor = config.Theme.WarningColor
+	return []ComponentContent{content}
+}
+
+// Icons of the operating systems and distributions, by os-release ID
+var OS_ICONS = map[string]string{
+	"linux":               "󰌽",
+	"macos":               "",
+	"windows":             "",
+	"fedora":              "",
+	"ubuntu":              "",
+	"arch":                "󰣇",
+	"debian":              "",
+	"alpine":              "",
+	"almalinux":           "",
+	"artix":               "",
+	"centos":              "",
+	"elementary":          "",
+	"endeavouros":         "",
+	"freebsd":             "",
+	"garuda":              "",
+	"gentoo":              "",
+	"kali":                "",
+	"linuxmint":           "",
+	"mageia":              "",
+	"manjaro":             "",
+	"neon":                "",
+	"nixos":               "",
+	"openbsd":             "",
+	"opensuse":            "",
+	"opensuse-leap":       "",
+	"opensuse-tumbleweed": "",
+	"suse":                "",
+	"parrot":              "",
+	"pop":                 "",
+	"raspbian":            "",
+	"rhel":                "",
+	"rocky":               "",
+	"slackware":           "",
+	"solus":               "",
+	"void":                "",
+	"zorin":               "",
+}
+
+var DEFAULT_OS_ICON = ""
+
+// GetOSIcon returns the icon of the OS, or of the closest distribution it is derived from.
+// The icons of the theme take precedence over the built-in ones.
 func GetOSIcon(config *Config) string {
+	osContext := config.Context.OS.GetContent()
+	for _, id := range osContext.IDs() {
+		if icon, ok := config.Theme.OSIcons[id]; ok {
+			return icon
+		}
+		if icon, ok := OS_ICONS[id]; ok {
+			return icon
+		}
+	}
+	if icon, ok := config.Theme.OSIcons["default"]; ok {
+		return icon
+	}
+	return DEFAULT_OS_ICON
+}
+
+var DEFAULT_EXIT_STATUS_SEPARATOR = "|"
 
-	switch config.Context.OS.GetContent() {
-	case oscontext.OSLinux:
-		return "󰌽"
-	case oscontext.OSMac:
-		return ""
-	case oscontext.OSFedora:
-		return ""
-	case oscontext.OSUbuntu:
-		return ""
-	case oscontext.OSArch:
-		return "󰣇"
-	case oscontext.OSDebian:
-		return ""
-	default:
-		return ""
+var DEFAULT_JOBS_SYMBOL = "✦"
+
+var DEFAULT_OS_FORMAT = "{icon} {name} {version}"
+
+var GIT_REMOTE_MODE_NAME = "name"
+var GIT_REMOTE_MODE_FORGE = "forge"
+
+var FORGE_ICONS = map[string]string{
+	"github":    "",
+	"gitlab":    "",
+	"bitbucket": "",
+	"gitea":     "",
+	"forgejo":   "",
+	"git":       "",
+}
+
+// Hosts of the public instances of the forges
+var FORGE_HOSTS = map[string]string{
+	"github.com":    "github",
+	"gitlab.com":    "gitlab",
+	"bitbucket.org": "bitbucket",
+	"gitea.com":     "gitea",
+	"codeberg.org":  "forgejo",
+}
+
+// GetForgeIcon returns the icon of the forge hosting the repository.
+// Custom hosts from the config are checked first, then the public instances, then the forge names in the host (e.g. gitlab.example.com).
+func GetForgeIcon(config *Config, host string) string {
+	if forge, ok := config.Options.GitRemote.Hosts[host]; ok {
+		return FORGE_ICONS[forge]
+	}
+	if forge, ok := FORGE_HOSTS[host]; ok {
+		return FORGE_ICONS[forge]
+	}
+	for _, forge := range []string{"github", "gitlab", "bitbucket", "gitea", "forgejo"} {
+		if strings.Contains(host, forge) {
+			return FORGE_ICONS[forge]
+		}
 	}
+	return FORGE_ICONS["git"]
 }
 
 func addColor(text string, fgcode string, bgcode string, bold bool, underline bool, shell context.ShellType) string {
@@ -68,7 +162,7 @@ func addColor(text string, fgcode string, bgcode string, bold bool, underline bo
 	ansiReset := "\x1b[0m"
 	ansiBold := "\x1b[1m"
 	ansiUnderline := "\x1b[4m"
-	// Escape codes for different shells
+	// Escape codes for different shells. Fish, PSReadLine and nushell measure the width of the prompt themselves, so the codes are left raw
 	escapeCodeStart := ""
 	escapeCodeEnd := ""
 
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"promptorium/internal/pkg/confpkg/context"
+	"promptorium/internal/pkg/confpkg/context/oscontext"
+	"testing"
+)
+
+func TestGetOSIcon(t *testing.T) {
+	tests := []struct {
+		name    string
+		os      oscontext.OS
+		osIcons map[string]string
+		want    string
+	}{
+		{"known id", oscontext.OS{ID: "ubuntu", IDLike: []string{"debian"}}, nil, OS_ICONS["ubuntu"]},
+		{"fallback to ID_LIKE", oscontext.OS{ID: "pop-derivative", IDLike: []string{"ubuntu", "debian"}}, nil, OS_ICONS["ubuntu"]},
+		{"fallback to the second ID_LIKE", oscontext.OS{ID: "unknown", IDLike: []string{"unknown-parent", "rhel"}}, nil, OS_ICONS["rhel"]},
+		{"theme icon of ID_LIKE before built-in icon of ID_LIKE", oscontext.OS{ID: "unknown", IDLike: []string{"debian"}}, map[string]string{"debian": "D"}, "D"},
+		{"unknown", oscontext.OS{ID: "unknown"}, nil, DEFAULT_OS_ICON},
+		{"theme default", oscontext.OS{ID: "unknown"}, map[string]string{"default": "?"}, "?"},
+	}
+	for _, test := range tests {
+		config := &Config{
+			Context: context.NewApplicationContextFromSnapshot(context.Snapshot{OS: test.os}),
+			Theme:   Theme{OSIcons: test.osIcons},
+		}
+		got := GetOSIcon(config)
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
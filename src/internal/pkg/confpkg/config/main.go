@@ -5,9 +5,10 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"promptorium/internal/log"
 	"promptorium/internal/pkg/confpkg/context"
-
-	"github.com/rs/zerolog/log"
+	"sort"
+	"time"
 )
 
 var Colors map[string]Color = getColors()
@@ -16,13 +17,20 @@ var DEFAULT_PLUGIN_PATH = filepath.Join(os.Getenv("HOME"), ".config", "promptori
 var DEFAULT_CONFIG_PATH = filepath.Join(os.Getenv("HOME"), ".config", "promptorium")
 var DEFAULT_PRESET_PATH = filepath.Join(os.Getenv("HOME"), ".config", "promptorium", "presets")
 
+var DEFAULT_PROMPT_TIMEOUT = 1 * time.Second
+var DEFAULT_TIMEOUT_MARKER = "…"
+var DEFAULT_GIT_CACHE_MAX_AGE = 30 * time.Second
+var DEFAULT_CMD_DURATION_MIN_TIME = 2 * time.Second
+
 // GetConfig reads the config file and theme file from the paths specified in
 // the passed arguments, and returns a parsed Config object.
 // If the configPath or themePath arguments are empty, it uses the default paths.
-func GetConfig(configPath string, shell string, exitCode int, version string) Config {
-
-	context := context.GetApplicationContext(shell, exitCode)
+func GetConfig(configPath string, values context.ShellValues, version string) Config {
+	return GetConfigWithContext(configPath, context.GetApplicationContext(values), version)
+}
 
+// GetConfigWithContext reads and parses the config file like GetConfig, using the given context instead of probing the system
+func GetConfigWithContext(configPath string, context *context.ApplicationContext, version string) Config {
 	conf, err := ParseConfig(GetRawConfig(configPath, context, version))
 	if err != nil {
 		log.Trace().Msg("Error parsing config")
@@ -32,6 +40,20 @@ func GetConfig(configPath string, shell string, exitCode int, version string) Co
 	return conf
 }
 
+// GetTransientConfigWithContext reads and parses the config file like GetConfigWithContext, with the transient_prompt
+// layout as the prompt. The prompt is empty if no transient prompt is configured.
+func GetTransientConfigWithContext(configPath string, context *context.ApplicationContext, version string) Config {
+	rawConfig := GetRawConfig(configPath, context, version)
+	rawConfig.Prompt = rawConfig.TransientPrompt
+	conf, err := ParseConfig(rawConfig)
+	if err != nil {
+		log.Trace().Msg("Error parsing config")
+		return conf
+	}
+
+	return conf
+}
+
 type Config struct {
 	Version    string
 	Prompt     [][]string
@@ -39,7 +61,7 @@ type Config struct {
 	Components map[string]Component
 	Context    *context.ApplicationContext
 	Options    ConfigOptions
-	Modules    map[string]ModuleEntry
+	Modules    ModuleRegistry
 }
 type Theme struct {
 	ComponentStartDivider      string
@@ -60,10 +82,51 @@ type Theme struct {
 	GitStatusColorNoUpstream   Color
 	ExitCodeColorOk            Color
 	ExitCodeColorError         Color
+	GitStateColorClean         Color
+	GitStateColorInProgress    Color
+	GitStateColorLocked        Color
+	OSIcons                    map[string]string
 }
 
 type ModuleEntry struct {
-	Get func(config *Config, component *Component) []ComponentContent
+	Name        string
+	Description string
+	Options     []ModuleOption
+	Providers   []context.Provider
+	Example     string
+	Get         func(config *Config, component *Component) []ComponentContent
+}
+
+// ModuleOption describes an option of the "options" config section that changes the output of a module
+type ModuleOption struct {
+	Name        string
+	Type        string
+	Default     string
+	Description string
+}
+
+// ModuleRegistry holds the modules that can be used by components of type "module", indexed by name
+type ModuleRegistry map[string]ModuleEntry
+
+// Register adds a module to the registry, replacing any module with the same name
+func (r ModuleRegistry) Register(module ModuleEntry) {
+	r[module.Name] = module
+}
+
+// Get returns the module with the given name
+func (r ModuleRegistry) Get(name string) (ModuleEntry, bool) {
+	module, ok := r[name]
+	return module, ok
+}
+
+// List returns the registered modules sorted by name
+func (r ModuleRegistry) List() []ModuleEntry {
+	result := make([]ModuleEntry, 0, len(r))
+	for _, module := range r {
+		result = append(result, module)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
 }
 
 type Component struct {
@@ -72,6 +135,7 @@ type Component struct {
 	Style   ComponentStyle
 	Content string
 	Icon    string
+	Timeout time.Duration
 }
 
 type ComponentType string
@@ -108,9 +172,10 @@ var Alignments = map[string]Align{
 }
 
 var ComponentTypes = map[string]ComponentType{
-	"module": ComponentType("module"),
-	"plugin": ComponentType("plugin"),
-	"text":   ComponentType("text"),
+	"module":  ComponentType("module"),
+	"plugin":  ComponentType("plugin"),
+	"text":    ComponentType("text"),
+	"command": ComponentType("command"),
 }
 
 type ModuleStyle struct {
@@ -131,9 +196,76 @@ type ColorName string
 
 // Options
 type ConfigOptions struct {
-	CWD CwdOptions
+	CWD         CwdOptions
+	Git         GitOptions
+	GitStatus   GitStatusOptions
+	GitRemote   GitRemoteOptions
+	OS          OSOptions
+	CmdDuration CmdDurationOptions
+	Jobs        JobsOptions
+	ExitStatus  ExitStatusOptions
+	Timeouts    TimeoutOptions
 }
 
 type CwdOptions struct {
-	HighlightGitRoot bool
+	HighlightGitRoot          bool
+	HighlightSuperprojectRoot bool
+}
+
+type GitOptions struct {
+	Cache       bool
+	CacheMaxAge time.Duration
+}
+
+type GitStatusOptions struct {
+	Format       string
+	Placeholders map[string]GitStatusPlaceholder
+}
+
+type GitStatusPlaceholder struct {
+	Symbol string
+	Color  RawColorName
+}
+
+type GitRemoteOptions struct {
+	// "name" to display the name of the remote, "forge" to display the forge icon and owner/repo
+	Mode string
+	// Forge of custom hosts, e.g. git.example.com: gitlab
+	Hosts map[string]string
+}
+
+type OSOptions struct {
+	// Format of the os module, with the placeholders {icon} {id} {name} {pretty_name} {version}
+	Format string
+}
+
+type CmdDurationOptions struct {
+	// Commands faster than MinTime are not displayed
+	MinTime time.Duration
+	// Commands slower than WarnTime are displayed with the warning color, 0 to disable
+	WarnTime time.Duration
+}
+
+type JobsOptions struct {
+	Symbol string
+	// The count is displayed after the symbol from Threshold jobs
+	Threshold int
+	// Displays the module when there is no job
+	ShowZero bool
+}
+
+type ExitStatusOptions struct {
+	// Displays the exit codes of all the commands of a pipeline, joined by Separator
+	Pipeline  bool
+	Separator string
+	// Displays the signal names and the meaning of the common exit codes instead of the numbers
+	Names bool
+	// Counts a failure of any command of the pipeline as a failure, like "set -o pipefail"
+	Pipefail bool
+}
+
+type TimeoutOptions struct {
+	Prompt    time.Duration
+	Providers map[context.Provider]time.Duration
+	Marker    string
 }
@@ -0,0 +1,212 @@
+package gitcontext
+
+// On-disk cache of the git context, for repositories where "git status" is slow.
+// Entries are stored in $XDG_CACHE_HOME/promptorium/git, one file per repository, and are reused
+// while the repository root, HEAD, the index, the refs and the upstream are unchanged.
+// Instead of scanning the working tree like git status, only the mtimes of the directories containing tracked files
+// are compared: they change when a file is created, deleted or renamed, which includes the files saved by most editors.
+// A file modified in place shows up once the entry is older than the max age.
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"promptorium/internal/log"
+	"promptorium/internal/utils"
+	"strings"
+	"time"
+)
+
+type CacheKey struct {
+	Root            string `json:"root"`
+	Head            string `json:"head"`
+	HeadTarget      string `json:"head_target"`
+	IndexModTime    int64  `json:"index_mtime"`
+	IndexSize       int64  `json:"index_size"`
+	PackedRefsMtime int64  `json:"packed_refs_mtime"`
+	FetchHeadMtime  int64  `json:"fetch_head_mtime"`
+	UpstreamMtime   int64  `json:"upstream_mtime"`
+	// Hash of the mtimes of the directories of the working tree containing tracked files
+	WorkTreeMtimes uint64 `json:"work_tree_mtimes"`
+}
+
+type CacheEntry struct {
+	Key        CacheKey   `json:"key"`
+	CreatedAt  time.Time  `json:"created_at"`
+	GitContext GitContext `json:"git"`
+	// Directories containing tracked files, reused while the index is unchanged
+	Dirs []string `json:"dirs,omitempty"`
+}
+
+// GetCacheDir returns the directory containing the cached git contexts
+func GetCacheDir() string {
+	return filepath.Join(utils.GetCacheDir(), "git")
+}
+
+// GetCachedGitState returns the cached git context of the repository containing the current directory
+// if the repository didn't change and the entry is younger than maxAge. Otherwise, it runs git status and updates the cache.
+func GetCachedGitState(gitContext chan GitContext, maxAge time.Duration) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		GetGitState(gitContext)
+		return
+	}
+	root := FindGitRoot(cwd)
+	if root == "" {
+		GetGitState(gitContext)
+		return
+	}
+
+	entry, ok := loadCacheEntry(root)
+	key, _, err := getCacheKey(root, entry)
+	if err == nil {
+		if ok && entry.Key == key && time.Since(entry.CreatedAt) < maxAge {
+			log.Trace().Msgf("Using cached git status for %s", root)
+			// The metadata is cheap to read, and can change without invalidating the entry (e.g. the step of a rebase)
+			result := ReadGitContext(root)
+			result.copyStatus(entry.GitContext)
+			gitContext <- result
+			return
+		}
+	}
+
+	status := make(chan GitContext, 1)
+	go GetGitState(status)
+	result := <-status
+
+	// The key is computed after running git status, because git status can refresh the index
+	key, dirs, err := getCacheKey(root, entry)
+	if err == nil && result.IsGitRepo {
+		saveCacheEntry(root, CacheEntry{Key: key, CreatedAt: time.Now(), GitContext: result, Dirs: dirs})
+	}
+	gitContext <- result
+}
+
+// GetCacheEntries returns all the entries of the git cache
+func GetCacheEntries() ([]CacheEntry, error) {
+	result := []CacheEntry{}
+	files, err := os.ReadDir(GetCacheDir())
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		entry, ok := readCacheEntry(filepath.Join(GetCacheDir(), file.Name()))
+		if ok {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+// ClearCache removes all the entries of the git cache
+func ClearCache() error {
+	return os.RemoveAll(GetCacheDir())
+}
+
+func getCacheEntryPath(root string) string {
+	hash := sha1.Sum([]byte(root))
+	return filepath.Join(GetCacheDir(), hex.EncodeToString(hash[:])+".json")
+}
+
+func loadCacheEntry(root string) (CacheEntry, bool) {
+	return readCacheEntry(getCacheEntryPath(root))
+}
+
+func readCacheEntry(path string) (CacheEntry, bool) {
+	entry := CacheEntry{}
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return entry, false
+	}
+	err = json.Unmarshal(file, &entry)
+	if err != nil {
+		log.Trace().Msgf("Could not parse git cache entry %s: %s", path, err)
+		return entry, false
+	}
+	return entry, true
+}
+
+func saveCacheEntry(root string, entry CacheEntry) {
+	file, err := json.Marshal(entry)
+	if err != nil {
+		log.Trace().Msgf("Could not encode git cache entry: %s", err)
+		return
+	}
+	err = utils.WriteFileAtomic(getCacheEntryPath(root), file)
+	if err != nil {
+		log.Trace().Msgf("Could not save git cache entry: %s", err)
+	}
+}
+
+// Returns the key of the current state of the repository, and the directories containing tracked files.
+// The directories of the previous entry are reused when the index didn't change.
+func getCacheKey(root string, previous CacheEntry) (CacheKey, []string, error) {
+	key := CacheKey{Root: root}
+
+	repo, ok := FindRepository(root)
+	if !ok {
+		return key, nil, os.ErrNotExist
+	}
+	gitDir := repo.GitDir
+
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return key, nil, err
+	}
+	key.Head = strings.TrimSpace(string(head))
+	if ref, ok := strings.CutPrefix(key.Head, "ref: "); ok {
+		ref = strings.TrimSpace(ref)
+		key.HeadTarget, _ = repo.ResolveRef(ref)
+		_, _, upstreamRef, hasUpstream := getUpstream(repo.ReadConfig(), strings.TrimPrefix(ref, "refs/heads/"))
+		if hasUpstream {
+			key.UpstreamMtime = getModTime(filepath.Join(repo.CommonDir, upstreamRef))
+		}
+	}
+
+	index, err := os.Stat(filepath.Join(gitDir, "index"))
+	if err == nil {
+		key.IndexModTime = index.ModTime().UnixNano()
+		key.IndexSize = index.Size()
+	}
+	key.PackedRefsMtime = getModTime(filepath.Join(repo.CommonDir, "packed-refs"))
+	key.FetchHeadMtime = getModTime(filepath.Join(gitDir, "FETCH_HEAD"))
+
+	dirs := previous.Dirs
+	if dirs == nil || previous.Key.IndexModTime != key.IndexModTime || previous.Key.IndexSize != key.IndexSize {
+		dirs, err = repo.ReadIndexDirs()
+		if err != nil {
+			// Without an index, e.g. in a new repository, only the root is checked
+			dirs = []string{"."}
+		}
+	}
+	key.WorkTreeMtimes = getDirsModTimes(root, dirs)
+
+	return key, dirs, nil
+}
+
+// Returns a hash of the mtimes of the directories, which changes when a directory gets or loses an entry
+func getDirsModTimes(root string, dirs []string) uint64 {
+	hash := fnv.New64a()
+	for _, dir := range dirs {
+		binary.Write(hash, binary.LittleEndian, getModTime(filepath.Join(root, dir)))
+	}
+	return hash.Sum64()
+}
+
+func getModTime(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.ModTime().UnixNano()
+}
@@ -0,0 +1,135 @@
+package gitcontext
+
+// Reader for the paths of the index (.git/index), used to find the directories of the working tree
+// containing tracked files without running git.
+// See https://git-scm.com/docs/index-format
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+var INDEX_SIGNATURE = []byte("DIRC")
+
+// Size of the stat data at the start of an index entry (ctime, mtime, dev, ino, mode, uid, gid, size)
+var INDEX_ENTRY_STAT_SIZE = 40
+
+var INDEX_FLAG_EXTENDED uint16 = 0x4000
+
+var errInvalidIndex = errors.New("invalid index")
+
+// ReadIndexDirs returns the directories of the working tree containing tracked files, relative to the root.
+// The root is returned as ".".
+func (repo Repository) ReadIndexDirs() ([]string, error) {
+	index, err := os.ReadFile(filepath.Join(repo.GitDir, "index"))
+	if err != nil {
+		return nil, err
+	}
+	hashSize := 20
+	if value, _ := repo.ReadConfig().Get("extensions", "", "objectformat"); value == "sha256" {
+		hashSize = 32
+	}
+	paths, err := parseIndexPaths(index, hashSize)
+	if err != nil {
+		return nil, err
+	}
+	return getIndexDirs(paths), nil
+}
+
+// Returns the paths of the entries of the index, in versions 2, 3 and 4 of the format
+func parseIndexPaths(index []byte, hashSize int) ([]string, error) {
+	if len(index) < 12 || !bytes.Equal(index[:4], INDEX_SIGNATURE) {
+		return nil, errInvalidIndex
+	}
+	version := binary.BigEndian.Uint32(index[4:8])
+	if version < 2 || version > 4 {
+		return nil, errInvalidIndex
+	}
+	count := binary.BigEndian.Uint32(index[8:12])
+
+	paths := make([]string, 0, count)
+	offset := 12
+	previous := ""
+	for i := uint32(0); i < count; i++ {
+		start := offset
+		offset += INDEX_ENTRY_STAT_SIZE + hashSize
+		if offset+2 > len(index) {
+			return nil, errInvalidIndex
+		}
+		flags := binary.BigEndian.Uint16(index[offset : offset+2])
+		offset += 2
+		if version >= 3 && flags&INDEX_FLAG_EXTENDED != 0 {
+			offset += 2
+		}
+
+		name := ""
+		if version == 4 {
+			// The path is stored as the number of bytes to remove from the end of the previous path, and the suffix to append
+			strip, size := readIndexVarint(index[min(offset, len(index)):])
+			if size == 0 || strip > len(previous) {
+				return nil, errInvalidIndex
+			}
+			offset += size
+			end := bytes.IndexByte(index[min(offset, len(index)):], 0)
+			if end < 0 {
+				return nil, errInvalidIndex
+			}
+			name = previous[:len(previous)-strip] + string(index[offset:offset+end])
+			offset += end + 1
+		} else {
+			end := bytes.IndexByte(index[min(offset, len(index)):], 0)
+			if end < 0 {
+				return nil, errInvalidIndex
+			}
+			name = string(index[offset : offset+end])
+			// Entries are padded with 1 to 8 NUL bytes to a multiple of 8 bytes
+			length := offset + end - start
+			offset = start + (length+8)/8*8
+		}
+		paths = append(paths, name)
+		previous = name
+	}
+	return paths, nil
+}
+
+// Reads a variable-length integer of the index format. The size is 0 if it is invalid
+func readIndexVarint(data []byte) (int, int) {
+	value := 0
+	for i, c := range data {
+		if i > 0 {
+			value++
+		}
+		value = value<<7 | int(c&0x7f)
+		if c&0x80 == 0 {
+			return value, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// Returns the sorted directories containing the paths, with all their parents and the root (".")
+func getIndexDirs(paths []string) []string {
+	dirs := map[string]bool{".": true}
+	for _, entry := range paths {
+		// Directories of a sparse index end with a slash
+		dir := path.Dir(entry)
+		if entry != "" && entry[len(entry)-1] == '/' {
+			dir = path.Clean(entry)
+		}
+		for dir != "." && dir != "/" && !dirs[dir] {
+			dirs[dir] = true
+			dir = path.Dir(dir)
+		}
+	}
+	result := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		result = append(result, dir)
+	}
+	sort.Strings(result)
+	return result
+}
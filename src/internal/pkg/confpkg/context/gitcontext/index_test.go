@@ -0,0 +1,91 @@
+package gitcontext
+
+import (
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadIndexDirs(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	files := []string{"README.md", "a b/c.txt", "a b/d/e.txt", "src/main.go", "src/pkg/deep/nested/file.go", "src/pkg/other.go"}
+	want := []string{".", "a b", "a b/d", "src", "src/pkg", "src/pkg/deep", "src/pkg/deep/nested"}
+
+	for _, version := range []string{"2", "3", "4"} {
+		t.Run("version "+version, func(t *testing.T) {
+			root := t.TempDir()
+			for _, file := range files {
+				writeFile(t, filepath.Join(root, filepath.FromSlash(file)), file)
+			}
+			runGit(t, root, "init", "-q")
+			runGit(t, root, "add", ".")
+			if version == "3" {
+				// Entries with the intent-to-add flag use the extended flags of version 3
+				writeFile(t, filepath.Join(root, "src", "new.go"), "new")
+				runGit(t, root, "add", "--intent-to-add", "src/new.go")
+			}
+			runGit(t, root, "update-index", "--index-version", version)
+
+			repo, ok := FindRepository(root)
+			if !ok {
+				t.Fatal("repository not found")
+			}
+			got, err := repo.ReadIndexDirs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseIndexPathsInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		index []byte
+	}{
+		{"empty", []byte{}},
+		{"bad signature", []byte("XXXX\x00\x00\x00\x02\x00\x00\x00\x00")},
+		{"unknown version", []byte("DIRC\x00\x00\x00\x05\x00\x00\x00\x00")},
+		{"truncated entry", []byte("DIRC\x00\x00\x00\x02\x00\x00\x00\x01\x00\x00")},
+	}
+	for _, test := range tests {
+		if _, err := parseIndexPaths(test.index, 20); err == nil {
+			t.Errorf("%s: got no error", test.name)
+		}
+	}
+}
+
+func TestGetIndexDirs(t *testing.T) {
+	tests := []struct {
+		paths []string
+		want  []string
+	}{
+		{[]string{}, []string{"."}},
+		{[]string{"a", "b"}, []string{"."}},
+		{[]string{"x/y/z"}, []string{".", "x", "x/y"}},
+		// Directory entries of a sparse index
+		{[]string{"a", "sparse/dir/"}, []string{".", "sparse", "sparse/dir"}},
+	}
+	for _, test := range tests {
+		got := getIndexDirs(test.paths)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("getIndexDirs(%v) = %v, want %v", test.paths, got, test.want)
+		}
+	}
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %s: %s", args, err, output)
+	}
+}
@@ -0,0 +1,81 @@
+package gitcontext
+
+// Detection of the repository layout: linked worktrees, submodules, bare, shallow and sparse repositories
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Returns the bare repository containing dir, recognized by its HEAD file and its objects and refs directories
+func findBareRepository(dir string) (Repository, bool) {
+	for dir != "" {
+		if exists(filepath.Join(dir, "HEAD")) && isDir(filepath.Join(dir, "objects")) && isDir(filepath.Join(dir, "refs")) {
+			return Repository{Root: dir, GitDir: dir, CommonDir: dir, IsBare: true}, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return Repository{}, false
+}
+
+// Sets the worktree, submodule, bare, shallow and sparse information of the repository
+func readLayout(repo Repository, config GitConfig, gitContext *GitContext) {
+	gitContext.IsBare = repo.IsBare
+	// The git directory of a linked worktree is <common dir>/worktrees/<name>
+	if repo.GitDir != repo.CommonDir {
+		gitContext.IsWorktree = true
+		gitContext.WorktreeName = filepath.Base(repo.GitDir)
+	}
+	gitContext.IsShallow = exists(filepath.Join(repo.CommonDir, "shallow"))
+	sparse, _ := config.Get("core", "", "sparseCheckout")
+	gitContext.IsSparse = sparse == "true"
+	if !repo.IsBare {
+		gitContext.SuperprojectRoot, gitContext.SubmodulePath = findSuperproject(repo.Root)
+	}
+}
+
+// Returns the root of the superproject of the working tree root and the path of the submodule in the superproject,
+// if the working tree root is listed in the .gitmodules file of the enclosing repository
+func findSuperproject(root string) (string, string) {
+	parent := filepath.Dir(root)
+	if parent == root {
+		return "", ""
+	}
+	superproject := FindGitRoot(parent)
+	if superproject == "" {
+		return "", ""
+	}
+	modules, err := ParseGitConfig(filepath.Join(superproject, ".gitmodules"))
+	if err != nil {
+		return "", ""
+	}
+	path, err := filepath.Rel(superproject, root)
+	if err != nil {
+		return "", ""
+	}
+	path = filepath.ToSlash(path)
+	for key, value := range modules {
+		if strings.HasPrefix(key, "submodule.") && strings.HasSuffix(key, ".path") && strings.Trim(value, "/") == path {
+			return superproject, path
+		}
+	}
+	return "", ""
+}
+
+// Reads the per-worktree config, used by sparse checkouts in linked worktrees
+func (repo Repository) readWorktreeConfig(config GitConfig) {
+	if enabled, _ := config.Get("extensions", "", "worktreeConfig"); enabled != "true" {
+		return
+	}
+	worktreeConfig, err := ParseGitConfig(filepath.Join(repo.GitDir, "config.worktree"))
+	if err != nil {
+		return
+	}
+	for key, value := range worktreeConfig {
+		config[key] = value
+	}
+}
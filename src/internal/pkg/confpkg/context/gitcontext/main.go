@@ -1,100 +1,244 @@
 package gitcontext
 
 import (
+	"os"
 	"os/exec"
-	"promptorium/internal/utils"
+	"path/filepath"
+	"promptorium/internal/log"
 	"strconv"
 	"strings"
-
-	"github.com/rs/zerolog/log"
 )
 
 // GitContext
 
 type GitContext struct {
-	IsGitRepo       bool
-	IsDirty         bool
-	IsDetachedHead  bool
-	HasUpstream     bool
-	LocalBranch     string
-	UpstreamBranch  string
-	Remote          string
-	Ahead           int
-	Behind          int
-	UnstagedChanges int
-	StagedChanges   int
-	UntrackedFiles  int
+	IsGitRepo      bool   `json:"is_git_repo"`
+	IsDirty        bool   `json:"is_dirty"`
+	IsDetachedHead bool   `json:"is_detached_head"`
+	HasUpstream    bool   `json:"has_upstream"`
+	LocalBranch    string `json:"local_branch"`
+	UpstreamBranch string `json:"upstream_branch"`
+	UpstreamRef    string `json:"upstream_ref"`
+	Remote         string `json:"remote"`
+	// URL of the upstream remote, or of origin when the branch has no upstream
+	RemoteURL        string           `json:"remote_url"`
+	RemoteRepository RemoteRepository `json:"remote_repository"`
+	Ahead            int              `json:"ahead"`
+	Behind           int              `json:"behind"`
+	UnstagedChanges  int              `json:"unstaged_changes"`
+	StagedChanges    int              `json:"staged_changes"`
+	UntrackedFiles   int              `json:"untracked_files"`
+	ConflictedFiles  int              `json:"conflicted_files"`
+	Stashes          int              `json:"stashes"`
+	Commit           string           `json:"commit"`
+	ShortCommit      string           `json:"short_commit"`
+	// Result of git describe --tags, only read when the git_describe provider is used
+	Describe    string `json:"describe"`
+	Tag         string `json:"tag"`
+	TagDistance int    `json:"tag_distance"`
+	// Operation in progress, with its current and total steps for rebases
+	Operation         GitOperation `json:"operation"`
+	OperationStep     int          `json:"operation_step"`
+	OperationTotal    int          `json:"operation_total"`
+	HasStaleIndexLock bool         `json:"has_stale_index_lock"`
+	// Name of the linked worktree, the main worktree is not a linked worktree
+	IsWorktree   bool   `json:"is_worktree"`
+	WorktreeName string `json:"worktree_name"`
+	// Root of the superproject and path of the submodule in it, when the repository is a submodule
+	SuperprojectRoot string `json:"superproject_root"`
+	SubmodulePath    string `json:"submodule_path"`
+	IsBare           bool   `json:"is_bare"`
+	IsShallow        bool   `json:"is_shallow"`
+	IsSparse         bool   `json:"is_sparse"`
 
-	gitRoot utils.CachedData[string]
-	GitRoot func() string
+	GitRoot func() string `json:"-"`
 }
 
 type changes struct {
 	StagedChanges   int
 	UnstagedChanges int
 	UntrackedFiles  int
+	ConflictedFiles int
 }
 
+// GetGitState gets the git context of the current directory, including the state of the working tree
 func GetGitState(gitContext chan GitContext) {
-	// Get the git context by shelling out to git
+	GetGitStateInDir("", gitContext)
+}
+
+// GetGitStateInDir gets the git context of the repository containing dir (the current directory if dir is empty),
+// including the state of the working tree, which requires running git status
+func GetGitStateInDir(dir string, gitContext chan GitContext) {
+	result := ReadGitContext(dir)
+	// Bare repositories have no working tree to get the state of
+	if !result.IsGitRepo || result.IsBare {
+		gitContext <- result
+		return
+	}
+
+	// Get the state of the working tree by shelling out to git
 	cmd := exec.Command("git", "status", "--porcelain=v2", "--branch", "-z")
+	cmd.Dir = dir
 	output, err := cmd.Output()
 	if err != nil {
-		log.Trace().Msgf("Error getting git context: %s", err)
-		gitContext <- GitContext{
-			IsGitRepo: false,
-		}
+		log.Trace().Msgf("Error getting git status: %s", err)
+		gitContext <- result
 		return
 	}
 	changes, err := getchanges(output)
 	if err != nil {
-		log.Trace().Msgf("Error parsing git context: %s", err)
-		gitContext <- GitContext{
-			IsGitRepo: false,
-		}
+		log.Trace().Msgf("Error parsing git status: %s", err)
+		gitContext <- result
 		return
 	}
-	localBranch := getLocalBranch(output)
-	upstreamBranch, remote, hasUpstream := getUpstreamBranchAndRemote(output)
 	ahead, behind := getAheadBehind(output)
+	result.IsDetachedHead, result.Commit = getHeadCommit(output)
+	if result.IsDetachedHead {
+		result.LocalBranch = ""
+	}
+	result.ShortCommit = getShortCommit(result.Commit)
+
+	log.Trace().Msgf("Git ahead: %d, behind: %d, unstaged changes: %d, staged changes: %d, untracked files: %d, conflicted files: %d", ahead, behind, changes.UnstagedChanges, changes.StagedChanges, changes.UntrackedFiles, changes.ConflictedFiles)
 
-	// Return the git context
-	log.Trace().Msgf("Found git repo")
-	log.Trace().Msgf("Git remote: %s", remote)
-	log.Trace().Msgf("Git branch: %s", localBranch)
-	log.Trace().Msgf("Git upstream: %s", upstreamBranch)
-	log.Trace().Msgf("Git ahead: %d, behind: %d, unstaged changes: %d, staged changes: %d, untracked files: %d", ahead, behind, changes.UnstagedChanges, changes.StagedChanges, changes.UntrackedFiles)
+	result.IsDirty = ahead > 0 || behind > 0 || changes.StagedChanges > 0 || changes.UnstagedChanges > 0 || changes.UntrackedFiles > 0 || changes.ConflictedFiles > 0
+	result.Ahead = ahead
+	result.Behind = behind
+	result.UnstagedChanges = changes.UnstagedChanges
+	result.StagedChanges = changes.StagedChanges
+	result.UntrackedFiles = changes.UntrackedFiles
+	result.ConflictedFiles = changes.ConflictedFiles
 
-	isDirty := ahead > 0 || behind > 0 || changes.StagedChanges > 0 || changes.UnstagedChanges > 0 || changes.UntrackedFiles > 0
+	gitContext <- result
+}
+
+// Copies the state of the working tree, as read by git status
+func (gitContext *GitContext) copyStatus(status GitContext) {
+	gitContext.IsDirty = status.IsDirty
+	gitContext.Ahead = status.Ahead
+	gitContext.Behind = status.Behind
+	gitContext.UnstagedChanges = status.UnstagedChanges
+	gitContext.StagedChanges = status.StagedChanges
+	gitContext.UntrackedFiles = status.UntrackedFiles
+	gitContext.ConflictedFiles = status.ConflictedFiles
+}
 
+// ReadGitContext reads the branch, the upstream and the root of the repository containing dir
+// (the current directory if dir is empty) without running git. The state of the working tree is left empty.
+func ReadGitContext(dir string) GitContext {
+	if dir == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return GitContext{IsGitRepo: false}
+		}
+		dir = cwd
+	}
+	repo, ok := FindRepository(dir)
+	if !ok {
+		log.Trace().Msgf("No git repository found in %s", dir)
+		return GitContext{IsGitRepo: false}
+	}
+
+	headRef, commit, err := repo.ReadHead()
+	if err != nil {
+		log.Trace().Msgf("Error reading git HEAD: %s", err)
+		return GitContext{IsGitRepo: false}
+	}
+
+	root := repo.Root
 	result := GitContext{
-		IsGitRepo:       true,
-		IsDirty:         isDirty,
-		IsDetachedHead:  false,
-		HasUpstream:     hasUpstream,
-		LocalBranch:     localBranch,
-		UpstreamBranch:  upstreamBranch,
-		Remote:          remote,
-		Ahead:           ahead,
-		Behind:          behind,
-		UnstagedChanges: changes.UnstagedChanges,
-		StagedChanges:   changes.StagedChanges,
-		UntrackedFiles:  changes.UntrackedFiles,
-		gitRoot:         utils.NewCachedData[string](getGitRoot, "git root"),
-	}
-
-	result.GitRoot = func() string { return result.gitRoot.GetContent() }
+		IsGitRepo: true,
+		GitRoot:   func() string { return root },
+	}
+	if headRef == "" {
+		result.IsDetachedHead = true
+		result.Commit = commit
+	} else {
+		// The commit is empty on a branch without commits
+		result.Commit, _ = repo.ResolveRef(headRef)
+		result.LocalBranch = strings.TrimPrefix(headRef, "refs/heads/")
+	}
+	config := repo.ReadConfig()
+	if result.LocalBranch != "" {
+		result.Remote, result.UpstreamBranch, result.UpstreamRef, result.HasUpstream = getUpstream(config, result.LocalBranch)
+	}
+	result.RemoteURL, result.RemoteRepository = getRemoteURL(config, result.Remote)
+	result.ShortCommit = getShortCommit(result.Commit)
+	readOperation(repo, &result)
+	readLayout(repo, config, &result)
+	result.Stashes = repo.CountStashes()
 
-	gitContext <- result
+	log.Trace().Msgf("Found git repo: %s", root)
+	log.Trace().Msgf("Git branch: %s, detached: %t, commit: %s", result.LocalBranch, result.IsDetachedHead, result.Commit)
+	log.Trace().Msgf("Git remote: %s", result.Remote)
+	log.Trace().Msgf("Git upstream: %s", result.UpstreamBranch)
+	log.Trace().Msgf("Git operation: %s %d/%d, stale index lock: %t", result.Operation, result.OperationStep, result.OperationTotal, result.HasStaleIndexLock)
+	log.Trace().Msgf("Git worktree: %s, submodule: %s in %s, bare: %t, shallow: %t, sparse: %t", result.WorktreeName, result.SubmodulePath, result.SuperprojectRoot, result.IsBare, result.IsShallow, result.IsSparse)
+
+	return result
+}
+
+// ReadDescribe sets the nearest tag of HEAD and the number of commits since that tag, as found by git describe --tags
+func ReadDescribe(dir string, gitContext *GitContext) {
+	cmd := exec.Command("git", "describe", "--tags", "--long")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		log.Trace().Msgf("No tag found: %s", err)
+		return
+	}
+	// The long format is <tag>-<distance>-g<commit>, and the tag can contain dashes
+	parts := strings.Split(strings.TrimSpace(string(output)), "-")
+	if len(parts) < 3 {
+		return
+	}
+	distance, err := strconv.Atoi(parts[len(parts)-2])
+	if err != nil {
+		return
+	}
+	gitContext.Tag = strings.Join(parts[:len(parts)-2], "-")
+	gitContext.TagDistance = distance
+	gitContext.Describe = gitContext.Tag
+	if distance > 0 {
+		gitContext.Describe = strings.Join(parts, "-")
+	}
+}
+
+// Returns the URL of the remote and the repository it points to. Without remote (or for local upstreams), origin is used
+func getRemoteURL(config GitConfig, remote string) (string, RemoteRepository) {
+	if remote == "" || remote == "." {
+		remote = "origin"
+	}
+	remoteURL, ok := config.Get("remote", remote, "url")
+	if !ok {
+		return "", RemoteRepository{}
+	}
+	repository, _ := ParseRemoteURL(remoteURL)
+	return remoteURL, repository
+}
+
+// Returns the remote, the branch and the ref of the upstream of a local branch, from the branch.<name>.remote and branch.<name>.merge config
+func getUpstream(config GitConfig, branch string) (string, string, string, bool) {
+	remote, hasRemote := config.Get("branch", branch, "remote")
+	merge, hasMerge := config.Get("branch", branch, "merge")
+	if !hasRemote || !hasMerge || remote == "" || merge == "" {
+		return "", "", "", false
+	}
+	upstreamBranch := strings.TrimPrefix(merge, "refs/heads/")
+	if remote == "." {
+		// The upstream is a local branch
+		return remote, upstreamBranch, merge, true
+	}
+	return remote, upstreamBranch, "refs/remotes/" + remote + "/" + upstreamBranch, true
 }
 
 func getchanges(gitStatus []byte) (changes, error) {
-	stagedChanges, unstagedChanges, untrackedFiles := 0, 0, 0
 	var changes changes
 
 	//Parse git status string
 	// We are parsing the output of git status --porcelain=v2
-	for _, line := range strings.Split(string(gitStatus), "\x00") {
+	fields := strings.Split(string(gitStatus), "\x00")
+	for i := 0; i < len(fields); i++ {
+		line := fields[i]
 		if line == "" {
 			continue
 		}
@@ -103,26 +247,32 @@ func getchanges(gitStatus []byte) (changes, error) {
 			continue
 		}
 		if strings.HasPrefix(line, "?") {
-			untrackedFiles++
+			changes.UntrackedFiles++
 			continue
 		}
-		if strings.HasPrefix(line, "1") || strings.HasPrefix(line, "2") {
+		if strings.HasPrefix(line, "u ") {
+			// Unmerged file, usually a merge conflict
+			changes.ConflictedFiles++
+			continue
+		}
+		if strings.HasPrefix(line, "1 ") || strings.HasPrefix(line, "2 ") {
 			// This means that there is a change in the file
 			// Now we need to parse the second part of the string to know whether the change is staged or not
 
 			XY := strings.Split(line, " ")[1]
 			if !strings.HasSuffix(XY, ".") {
-				unstagedChanges++
+				changes.UnstagedChanges++
 			}
 			if !strings.HasPrefix(XY, ".") {
-				stagedChanges++
+				changes.StagedChanges++
+			}
+			if strings.HasPrefix(line, "2 ") {
+				// Renames and copies are followed by the original path
+				i++
 			}
 			continue
 		}
 	}
-	changes.StagedChanges = stagedChanges
-	changes.UnstagedChanges = unstagedChanges
-	changes.UntrackedFiles = untrackedFiles
 	return changes, nil
 }
 
@@ -146,42 +296,38 @@ func getAheadBehind(gitStatus []byte) (int, int) {
 	return ahead, behind
 }
 
-func getUpstreamBranchAndRemote(gitStatus []byte) (string, string, bool) {
-	upstreamBranch := ""
-	remote := ""
-	hasUpstream := false
+// Returns whether HEAD is detached and the commit id, from the # branch.head and # branch.oid headers
+func getHeadCommit(gitStatus []byte) (bool, string) {
+	isDetached, commit := false, ""
 	for _, line := range strings.Split(string(gitStatus), "\x00") {
-		if line == "" {
-			continue
+		if line == "# branch.head (detached)" {
+			isDetached = true
 		}
-		if strings.HasPrefix(line, "# branch.upstream") {
-			// Header lines are parsed in another function
-			upstream := strings.TrimPrefix(line, "# branch.upstream ")
-			remote = strings.Split(upstream, "/")[0]
-			upstreamBranch = strings.Split(upstream, "/")[1]
-			hasUpstream = true
-			break
+		if oid, ok := strings.CutPrefix(line, "# branch.oid "); ok && oid != "(initial)" {
+			commit = oid
 		}
 	}
-	return upstreamBranch, remote, hasUpstream
+	return isDetached, commit
 }
 
-func getLocalBranch(gitStatus []byte) string {
-	localBranch := ""
-	for _, line := range strings.Split(string(gitStatus), "\x00") {
-		if line == "" {
-			continue
+func getShortCommit(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
+
+// FindGitRoot returns the closest directory containing a .git entry, starting from dir and walking up
+func FindGitRoot(dir string) string {
+	for dir != "" {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
 		}
-		if strings.HasPrefix(line, "# branch.head ") {
-			// Header lines are parsed in another function
-			localBranch = strings.TrimPrefix(line, "# branch.head ")
+		parent := filepath.Dir(dir)
+		if parent == dir {
 			break
 		}
+		dir = parent
 	}
-	return localBranch
-}
-
-func getGitRoot(result chan string) {
-	gitRoot, _ := exec.Command("git", "rev-parse", "--show-toplevel").Output()
-	result <- strings.TrimSuffix(string(gitRoot), "\n")
+	return ""
 }
@@ -0,0 +1,141 @@
+package gitcontext
+
+import (
+	"strings"
+	"testing"
+)
+
+// Joins the lines of git status --porcelain=v2 --branch -z, which are terminated by NUL bytes
+func porcelain(lines ...string) []byte {
+	return []byte(strings.Join(lines, "\x00") + "\x00")
+}
+
+func TestGetChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		status []byte
+		want   changes
+	}{
+		{
+			name: "clean",
+			status: porcelain(
+				"# branch.oid ea5b4cb28b5ae62aa0a359f6ebdd80f75b9ba486",
+				"# branch.head main",
+			),
+			want: changes{},
+		},
+		{
+			name: "modified, staged and both",
+			status: porcelain(
+				"# branch.oid ea5b4cb28b5ae62aa0a359f6ebdd80f75b9ba486",
+				"# branch.head main",
+				"1 .M N... 100644 100644 100644 28ce6a8b26aa170e1de65536fe8abe1832bd3242 28ce6a8b26aa170e1de65536fe8abe1832bd3242 mod.txt",
+				"1 A. N... 000000 100644 100644 0000000000000000000000000000000000000000 b4785957bc986dc39c629de9fac9df46972c00fc added.txt",
+				"1 AM N... 000000 100644 100644 0000000000000000000000000000000000000000 b4785957bc986dc39c629de9fac9df46972c00fc staged.txt",
+			),
+			want: changes{StagedChanges: 2, UnstagedChanges: 2},
+		},
+		{
+			name: "rename with spaces",
+			status: porcelain(
+				"# branch.oid ea5b4cb28b5ae62aa0a359f6ebdd80f75b9ba486",
+				"# branch.head main",
+				"2 R. N... 100644 100644 100644 78981922613b2afb6025042ff6bd878ac1994e85 78981922613b2afb6025042ff6bd878ac1994e85 R100 new name.txt",
+				"old name.txt",
+			),
+			want: changes{StagedChanges: 1},
+		},
+		{
+			name: "original path of a rename looking like an entry",
+			status: porcelain(
+				"2 RM N... 100644 100644 100644 78981922613b2afb6025042ff6bd878ac1994e85 78981922613b2afb6025042ff6bd878ac1994e85 R100 b.txt",
+				"? a.txt",
+				"2 C. N... 100644 100644 100644 78981922613b2afb6025042ff6bd878ac1994e85 78981922613b2afb6025042ff6bd878ac1994e85 C75 copy.txt",
+				"1 .M weird name",
+			),
+			want: changes{StagedChanges: 2, UnstagedChanges: 1},
+		},
+		{
+			name: "conflicts",
+			status: porcelain(
+				"# branch.oid ea5b4cb28b5ae62aa0a359f6ebdd80f75b9ba486",
+				"# branch.head main",
+				"u UU N... 100644 100644 100644 100644 f2ad6c76f0115a6ba5b00456a849810e7ec0af20 ba2906d0666cf726c7eaadd2cd3db615dedfdf3a e45c9c2666d44e0327c1f9c239a74c508336053e conflict.txt",
+				"u AA N... 000000 100644 100644 100644 0000000000000000000000000000000000000000 ba2906d0666cf726c7eaadd2cd3db615dedfdf3a e45c9c2666d44e0327c1f9c239a74c508336053e both added.txt",
+			),
+			want: changes{ConflictedFiles: 2},
+		},
+		{
+			name: "untracked files with spaces",
+			status: porcelain(
+				"# branch.oid ea5b4cb28b5ae62aa0a359f6ebdd80f75b9ba486",
+				"# branch.head main",
+				"? untracked file.txt",
+				"? new dir/",
+			),
+			want: changes{UntrackedFiles: 2},
+		},
+		{
+			name: "everything",
+			status: porcelain(
+				"# branch.oid ea5b4cb28b5ae62aa0a359f6ebdd80f75b9ba486",
+				"# branch.head main",
+				"1 .M N... 100644 100644 100644 28ce6a8b26aa170e1de65536fe8abe1832bd3242 28ce6a8b26aa170e1de65536fe8abe1832bd3242 mod.txt",
+				"2 R. N... 100644 100644 100644 78981922613b2afb6025042ff6bd878ac1994e85 78981922613b2afb6025042ff6bd878ac1994e85 R100 new name.txt",
+				"old name.txt",
+				"1 AM N... 000000 100644 100644 0000000000000000000000000000000000000000 b4785957bc986dc39c629de9fac9df46972c00fc staged.txt",
+				"u UU N... 100644 100644 100644 100644 f2ad6c76f0115a6ba5b00456a849810e7ec0af20 ba2906d0666cf726c7eaadd2cd3db615dedfdf3a e45c9c2666d44e0327c1f9c239a74c508336053e conflict.txt",
+				"? untracked file.txt",
+			),
+			want: changes{StagedChanges: 2, UnstagedChanges: 2, UntrackedFiles: 1, ConflictedFiles: 1},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := getchanges(test.status)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetAheadBehind(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        []byte
+		ahead, behind int
+	}{
+		{"no upstream", porcelain("# branch.oid ea5b4cb28b5ae62aa0a359f6ebdd80f75b9ba486", "# branch.head main"), 0, 0},
+		{"up to date", porcelain("# branch.head main", "# branch.upstream origin/main", "# branch.ab +0 -0"), 0, 0},
+		{"diverged", porcelain("# branch.head main", "# branch.upstream origin/main", "# branch.ab +3 -12", "? a b"), 3, 12},
+	}
+	for _, test := range tests {
+		ahead, behind := getAheadBehind(test.status)
+		if ahead != test.ahead || behind != test.behind {
+			t.Errorf("%s: got +%d -%d, want +%d -%d", test.name, ahead, behind, test.ahead, test.behind)
+		}
+	}
+}
+
+func TestGetHeadCommit(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     []byte
+		isDetached bool
+		commit     string
+	}{
+		{"branch", porcelain("# branch.oid ea5b4cb28b5ae62aa0a359f6ebdd80f75b9ba486", "# branch.head main"), false, "ea5b4cb28b5ae62aa0a359f6ebdd80f75b9ba486"},
+		{"detached", porcelain("# branch.oid ea5b4cb28b5ae62aa0a359f6ebdd80f75b9ba486", "# branch.head (detached)"), true, "ea5b4cb28b5ae62aa0a359f6ebdd80f75b9ba486"},
+		{"initial commit", porcelain("# branch.oid (initial)", "# branch.head main"), false, ""},
+	}
+	for _, test := range tests {
+		isDetached, commit := getHeadCommit(test.status)
+		if isDetached != test.isDetached || commit != test.commit {
+			t.Errorf("%s: got %t %q, want %t %q", test.name, isDetached, commit, test.isDetached, test.commit)
+		}
+	}
+}
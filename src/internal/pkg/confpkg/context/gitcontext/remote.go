@@ -0,0 +1,44 @@
+package gitcontext
+
+import (
+	"net/url"
+	"strings"
+)
+
+// RemoteRepository holds the parts of a remote URL which identify a repository on a forge
+type RemoteRepository struct {
+	Host  string `json:"host"`
+	Owner string `json:"owner"`
+	Repo  string `json:"repo"`
+}
+
+// ParseRemoteURL parses the host, the owner and the repository name of a remote URL.
+// The URL can be an ssh or https URL (ssh://git@github.com/owner/repo.git) or an scp-like address (git@github.com:owner/repo.git).
+// The owner can contain slashes, for the nested groups of GitLab. Local paths are not parsed.
+func ParseRemoteURL(remoteURL string) (RemoteRepository, bool) {
+	host, path := "", ""
+	if strings.Contains(remoteURL, "://") {
+		parsed, err := url.Parse(remoteURL)
+		if err != nil || parsed.Scheme == "file" {
+			return RemoteRepository{}, false
+		}
+		host, path = parsed.Hostname(), parsed.Path
+	} else {
+		// scp-like address: [user@]host:path, where the host can't contain a slash
+		address, scpPath, ok := strings.Cut(remoteURL, ":")
+		if !ok || strings.Contains(address, "/") {
+			return RemoteRepository{}, false
+		}
+		if _, hostname, hasUser := strings.Cut(address, "@"); hasUser {
+			address = hostname
+		}
+		host, path = address, scpPath
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	separator := strings.LastIndex(path, "/")
+	if host == "" || separator <= 0 {
+		return RemoteRepository{}, false
+	}
+	return RemoteRepository{Host: strings.ToLower(host), Owner: path[:separator], Repo: path[separator+1:]}, true
+}
@@ -0,0 +1,277 @@
+package gitcontext
+
+// Native reader for the git metadata which doesn't require running git: the repository layout,
+// HEAD, the loose and packed refs and the branch configuration.
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"promptorium/internal/log"
+	"strings"
+)
+
+// Repository holds the locations of the files of a git repository
+type Repository struct {
+	// Root of the working tree
+	Root string
+	// Git directory of the working tree (the target of the .git file for linked worktrees and submodules)
+	GitDir string
+	// Directory holding the refs and the config shared by all the worktrees
+	CommonDir string
+	// Bare repositories have no working tree, their root is the git directory
+	IsBare bool
+}
+
+// FindRepository finds the repository containing dir, by walking up to the closest .git entry,
+// or to the closest bare repository when there is none
+func FindRepository(dir string) (Repository, bool) {
+	root := FindGitRoot(dir)
+	if root == "" {
+		return findBareRepository(dir)
+	}
+	gitDir, err := resolveGitDir(root)
+	if err != nil {
+		log.Trace().Msgf("Could not read git directory of %s: %s", root, err)
+		return Repository{}, false
+	}
+
+	commonDir := gitDir
+	file, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err == nil {
+		commonDir = strings.TrimSpace(string(file))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+	}
+
+	return Repository{Root: root, GitDir: gitDir, CommonDir: filepath.Clean(commonDir)}, true
+}
+
+// Returns the git directory of the working tree root, following the "gitdir:" indirection of .git files
+func resolveGitDir(root string) (string, error) {
+	gitDir := filepath.Join(root, ".git")
+	info, err := os.Stat(gitDir)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return gitDir, nil
+	}
+	file, err := os.ReadFile(gitDir)
+	if err != nil {
+		return "", err
+	}
+	path, ok := strings.CutPrefix(strings.TrimSpace(string(file)), "gitdir:")
+	if !ok {
+		return "", errors.New("invalid .git file")
+	}
+	path = strings.TrimSpace(path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	return filepath.Clean(path), nil
+}
+
+// ReadHead returns the ref HEAD points to (e.g. refs/heads/main), or the commit id if HEAD is detached
+func (repo Repository) ReadHead() (ref string, commit string, err error) {
+	file, err := os.ReadFile(filepath.Join(repo.GitDir, "HEAD"))
+	if err != nil {
+		return "", "", err
+	}
+	head := strings.TrimSpace(string(file))
+	if ref, ok := strings.CutPrefix(head, "ref:"); ok {
+		return strings.TrimSpace(ref), "", nil
+	}
+	return "", head, nil
+}
+
+// ResolveRef returns the commit id of a ref, looking for a loose ref first and then in the packed refs.
+// Symbolic refs are followed.
+func (repo Repository) ResolveRef(ref string) (string, error) {
+	for range 10 {
+		value, err := repo.readLooseRef(ref)
+		if errors.Is(err, os.ErrNotExist) {
+			return repo.readPackedRef(ref)
+		}
+		if err != nil {
+			return "", err
+		}
+		target, ok := strings.CutPrefix(value, "ref:")
+		if !ok {
+			return value, nil
+		}
+		ref = strings.TrimSpace(target)
+	}
+	return "", errors.New("too many levels of symbolic refs")
+}
+
+func (repo Repository) readLooseRef(ref string) (string, error) {
+	// HEAD and the other pseudo refs belong to the worktree, the refs/ hierarchy is shared
+	dir := repo.CommonDir
+	if !strings.HasPrefix(ref, "refs/") {
+		dir = repo.GitDir
+	}
+	file, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(ref)))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(file)), nil
+}
+
+func (repo Repository) readPackedRef(ref string) (string, error) {
+	file, err := os.Open(filepath.Join(repo.CommonDir, "packed-refs"))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Comments start with #, and peeled tags with ^
+		if line == "" || line[0] == '#' || line[0] == '^' {
+			continue
+		}
+		commit, name, ok := strings.Cut(line, " ")
+		if ok && name == ref {
+			return commit, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", os.ErrNotExist
+}
+
+// CountStashes returns the number of stash entries, which are the entries of the refs/stash reflog
+func (repo Repository) CountStashes() int {
+	if _, err := repo.ResolveRef("refs/stash"); err != nil {
+		return 0
+	}
+	file, err := os.ReadFile(filepath.Join(repo.CommonDir, "logs", "refs", "stash"))
+	if err != nil {
+		// The reflog can be disabled, in which case only the latest stash is known
+		return 1
+	}
+	return strings.Count(strings.TrimRight(string(file), "\n"), "\n") + 1
+}
+
+// ReadConfig reads the config of the repository, and the config of the worktree when enabled.
+// Included files are not followed.
+func (repo Repository) ReadConfig() GitConfig {
+	config, err := ParseGitConfig(filepath.Join(repo.CommonDir, "config"))
+	if err != nil {
+		log.Trace().Msgf("Could not read git config: %s", err)
+	}
+	repo.readWorktreeConfig(config)
+	return config
+}
+
+// GitConfig maps the config keys (e.g. "branch.main.remote") to their last value.
+// Section and variable names are lowercase, subsection names keep their case.
+type GitConfig map[string]string
+
+// Get returns the value of a key, given as section, optional subsection and name
+func (config GitConfig) Get(section string, subsection string, name string) (string, bool) {
+	key := strings.ToLower(section) + "." + strings.ToLower(name)
+	if subsection != "" {
+		key = strings.ToLower(section) + "." + subsection + "." + strings.ToLower(name)
+	}
+	value, ok := config[key]
+	return value, ok
+}
+
+// ParseGitConfig parses a git config file
+func ParseGitConfig(path string) (GitConfig, error) {
+	config := GitConfig{}
+	file, err := os.Open(path)
+	if err != nil {
+		return config, err
+	}
+	defer file.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+
+		if line[0] == '[' {
+			end := strings.LastIndex(line, "]")
+			if end < 0 {
+				continue
+			}
+			section = parseConfigSection(line[1:end])
+			line = strings.TrimSpace(line[end+1:])
+			if line == "" || line[0] == '#' || line[0] == ';' {
+				continue
+			}
+		}
+
+		name, value, hasValue := strings.Cut(line, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !hasValue {
+			// A variable without value is a true boolean
+			config[section+"."+stripConfigComment(name)] = "true"
+			continue
+		}
+		config[section+"."+name] = parseConfigValue(value)
+	}
+	return config, scanner.Err()
+}
+
+// Returns the section name of a section header: [section "subsection"] or the legacy [section.subsection]
+func parseConfigSection(header string) string {
+	name, subsection, ok := strings.Cut(header, " ")
+	if !ok {
+		name, subsection, ok = strings.Cut(header, ".")
+		if !ok {
+			return strings.ToLower(strings.TrimSpace(header))
+		}
+		return strings.ToLower(name) + "." + strings.ToLower(subsection)
+	}
+	subsection = strings.TrimSpace(subsection)
+	subsection = strings.TrimSuffix(strings.TrimPrefix(subsection, `"`), `"`)
+	subsection = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(subsection)
+	return strings.ToLower(name) + "." + subsection
+}
+
+// Returns the value of a variable, without the quotes, the escapes and the trailing comment
+func parseConfigValue(value string) string {
+	var builder strings.Builder
+	inQuotes := false
+	escaped := false
+	for _, char := range strings.TrimSpace(value) {
+		switch {
+		case escaped:
+			switch char {
+			case 'n':
+				builder.WriteRune('\n')
+			case 't':
+				builder.WriteRune('\t')
+			default:
+				builder.WriteRune(char)
+			}
+			escaped = false
+		case char == '\\':
+			escaped = true
+		case char == '"':
+			inQuotes = !inQuotes
+		case (char == '#' || char == ';') && !inQuotes:
+			return strings.TrimSpace(builder.String())
+		default:
+			builder.WriteRune(char)
+		}
+	}
+	return strings.TrimSpace(builder.String())
+}
+
+func stripConfigComment(name string) string {
+	name, _, _ = strings.Cut(name, "#")
+	name, _, _ = strings.Cut(name, ";")
+	return strings.TrimSpace(name)
+}
@@ -0,0 +1,216 @@
+package gitcontext
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseGitConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   map[string]string
+	}{
+		{
+			name:   "section",
+			config: "[core]\n\tbare = false\n\tRepositoryFormatVersion = 0\n",
+			want:   map[string]string{"core.bare": "false", "core.repositoryformatversion": "0"},
+		},
+		{
+			name:   "quoted subsection keeps its case",
+			config: "[remote \"Origin\"]\n\turl = git@github.com:owner/repo.git\n",
+			want:   map[string]string{"remote.Origin.url": "git@github.com:owner/repo.git"},
+		},
+		{
+			name:   "escaped quote in subsection",
+			config: "[branch \"a\\\"b\"]\n\tremote = origin\n",
+			want:   map[string]string{"branch.a\"b.remote": "origin"},
+		},
+		{
+			name:   "legacy subsection is lowercased",
+			config: "[Branch.Main]\n\tremote = origin\n",
+			want:   map[string]string{"branch.main.remote": "origin"},
+		},
+		{
+			name:   "variable on the header line",
+			config: "[core] bare = true\n",
+			want:   map[string]string{"core.bare": "true"},
+		},
+		{
+			name:   "variable without value",
+			config: "[core]\n\tbare ; comment\n",
+			want:   map[string]string{"core.bare": "true"},
+		},
+		{
+			name:   "quoted value with comment characters",
+			config: "[alias]\n\tl = \"log; # not a comment\" # comment\n",
+			want:   map[string]string{"alias.l": "log; # not a comment"},
+		},
+		{
+			name:   "escapes in value",
+			config: "[alias]\n\tx = a\\tb\\\\c\n",
+			want:   map[string]string{"alias.x": "a\tb\\c"},
+		},
+		{
+			name:   "comments",
+			config: "# comment\n; comment\n[core]\n\t# bare = true\n",
+			want:   map[string]string{},
+		},
+		{
+			name:   "include is not followed",
+			config: "[include]\n\tpath = other.config\n[core]\n\tbare = false\n",
+			want:   map[string]string{"include.path": "other.config", "core.bare": "false"},
+		},
+		{
+			name:   "last value wins",
+			config: "[core]\n\tbare = true\n[core]\n\tbare = false\n",
+			want:   map[string]string{"core.bare": "false"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config")
+			writeFile(t, path, test.config)
+			got, err := ParseGitConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+			for key, value := range test.want {
+				if got[key] != value {
+					t.Errorf("%s: got %q, want %q", key, got[key], value)
+				}
+			}
+		})
+	}
+}
+
+func TestGitConfigGet(t *testing.T) {
+	config := GitConfig{"branch.Main.remote": "origin", "core.bare": "false"}
+	tests := []struct {
+		section, subsection, name string
+		want                      string
+		ok                        bool
+	}{
+		{"branch", "Main", "remote", "origin", true},
+		{"BRANCH", "Main", "Remote", "origin", true},
+		{"branch", "main", "remote", "", false},
+		{"core", "", "bare", "false", true},
+		{"core", "", "editor", "", false},
+	}
+	for _, test := range tests {
+		got, ok := config.Get(test.section, test.subsection, test.name)
+		if got != test.want || ok != test.ok {
+			t.Errorf("Get(%q, %q, %q) = %q, %t, want %q, %t", test.section, test.subsection, test.name, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestReadPackedRef(t *testing.T) {
+	packedRefs := `# pack-refs with: peeled fully-peeled sorted
+1111111111111111111111111111111111111111 refs/heads/main
+2222222222222222222222222222222222222222 refs/tags/v1.0
+^3333333333333333333333333333333333333333
+4444444444444444444444444444444444444444 refs/remotes/origin/main
+`
+	tests := []struct {
+		ref  string
+		want string
+		ok   bool
+	}{
+		{"refs/heads/main", "1111111111111111111111111111111111111111", true},
+		{"refs/tags/v1.0", "2222222222222222222222222222222222222222", true},
+		{"refs/remotes/origin/main", "4444444444444444444444444444444444444444", true},
+		{"refs/heads/other", "", false},
+		{"3333333333333333333333333333333333333333", "", false},
+	}
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "packed-refs"), packedRefs)
+	repo := Repository{GitDir: dir, CommonDir: dir}
+	for _, test := range tests {
+		got, err := repo.readPackedRef(test.ref)
+		if got != test.want || (err == nil) != test.ok {
+			t.Errorf("readPackedRef(%q) = %q, %v, want %q", test.ref, got, err, test.want)
+		}
+	}
+}
+
+func TestResolveRef(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "packed-refs"), "1111111111111111111111111111111111111111 refs/heads/main\n")
+	writeFile(t, filepath.Join(dir, "refs", "heads", "loose"), "2222222222222222222222222222222222222222\n")
+	writeFile(t, filepath.Join(dir, "refs", "remotes", "origin", "HEAD"), "ref: refs/heads/main\n")
+	repo := Repository{GitDir: dir, CommonDir: dir}
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"refs/heads/main", "1111111111111111111111111111111111111111"},
+		{"refs/heads/loose", "2222222222222222222222222222222222222222"},
+		{"refs/remotes/origin/HEAD", "1111111111111111111111111111111111111111"},
+	}
+	for _, test := range tests {
+		got, err := repo.ResolveRef(test.ref)
+		if err != nil || got != test.want {
+			t.Errorf("ResolveRef(%q) = %q, %v, want %q", test.ref, got, err, test.want)
+		}
+	}
+}
+
+func TestResolveGitDir(t *testing.T) {
+	tests := []struct {
+		name    string
+		gitFile string
+		want    string
+		ok      bool
+	}{
+		{"relative gitdir", "gitdir: ../main/.git/worktrees/work\n", "main/.git/worktrees/work", true},
+		{"absolute gitdir", "gitdir: /repos/main/.git/modules/sub", "/repos/main/.git/modules/sub", true},
+		{"invalid file", "not a git file", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			base := t.TempDir()
+			root := filepath.Join(base, "work")
+			writeFile(t, filepath.Join(root, ".git"), test.gitFile)
+			got, err := resolveGitDir(root)
+			if (err == nil) != test.ok {
+				t.Fatalf("got error %v", err)
+			}
+			want := test.want
+			if test.ok && !filepath.IsAbs(want) {
+				want = filepath.Join(base, want)
+			}
+			if got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestResolveGitDirDirectory(t *testing.T) {
+	root := t.TempDir()
+	err := os.Mkdir(filepath.Join(root, ".git"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := resolveGitDir(root)
+	if err != nil || got != filepath.Join(root, ".git") {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
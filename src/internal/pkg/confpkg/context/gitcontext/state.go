@@ -0,0 +1,75 @@
+package gitcontext
+
+// Detection of the operation in progress (rebase, merge, cherry-pick...) from the files git leaves in the git directory
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type GitOperation string
+
+const (
+	OperationNone       GitOperation = ""
+	OperationRebase     GitOperation = "rebase"
+	OperationAm         GitOperation = "am"
+	OperationMerge      GitOperation = "merge"
+	OperationCherryPick GitOperation = "cherry_pick"
+	OperationRevert     GitOperation = "revert"
+	OperationBisect     GitOperation = "bisect"
+)
+
+// An index.lock older than this was most likely left behind by a git process which crashed
+var STALE_INDEX_LOCK_AGE = 10 * time.Second
+
+// Sets the operation in progress, its steps and whether the index lock is stale
+func readOperation(repo Repository, gitContext *GitContext) {
+	gitDir := repo.GitDir
+	switch {
+	case isDir(filepath.Join(gitDir, "rebase-merge")):
+		gitContext.Operation = OperationRebase
+		gitContext.OperationStep = readNumber(filepath.Join(gitDir, "rebase-merge", "msgnum"))
+		gitContext.OperationTotal = readNumber(filepath.Join(gitDir, "rebase-merge", "end"))
+	case isDir(filepath.Join(gitDir, "rebase-apply")):
+		// rebase-apply is used by "git am" and by rebases with the apply backend
+		gitContext.Operation = OperationAm
+		if exists(filepath.Join(gitDir, "rebase-apply", "rebasing")) {
+			gitContext.Operation = OperationRebase
+		}
+		gitContext.OperationStep = readNumber(filepath.Join(gitDir, "rebase-apply", "next"))
+		gitContext.OperationTotal = readNumber(filepath.Join(gitDir, "rebase-apply", "last"))
+	case exists(filepath.Join(gitDir, "MERGE_HEAD")):
+		gitContext.Operation = OperationMerge
+	case exists(filepath.Join(gitDir, "CHERRY_PICK_HEAD")):
+		gitContext.Operation = OperationCherryPick
+	case exists(filepath.Join(gitDir, "REVERT_HEAD")):
+		gitContext.Operation = OperationRevert
+	case exists(filepath.Join(gitDir, "BISECT_LOG")):
+		gitContext.Operation = OperationBisect
+	}
+
+	info, err := os.Stat(filepath.Join(gitDir, "index.lock"))
+	gitContext.HasStaleIndexLock = err == nil && time.Since(info.ModTime()) > STALE_INDEX_LOCK_AGE
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func readNumber(path string) int {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	number, _ := strconv.Atoi(strings.TrimSpace(string(file)))
+	return number
+}
@@ -1,23 +1,88 @@
 package context
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"promptorium/internal/daemon"
+	"promptorium/internal/log"
 	"promptorium/internal/pkg/confpkg/context/gitcontext"
 	"promptorium/internal/pkg/confpkg/context/oscontext"
+	"promptorium/internal/pkg/confpkg/context/vcscontext"
 	"promptorium/internal/utils"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/rs/zerolog/log"
 	"golang.org/x/term"
 )
 
 type ApplicationContext struct {
+	CreatedAt     time.Time
 	ExitCode      utils.CachedData[int]
+	PipeStatus    utils.CachedData[[]int]
+	CmdDuration   utils.CachedData[time.Duration]
+	Jobs          utils.CachedData[int]
 	CWD           utils.CachedData[string]
 	GitContext    utils.CachedData[gitcontext.GitContext]
+	VCSContext    utils.CachedData[vcscontext.VCSContext]
 	OS            utils.CachedData[oscontext.OS]
 	Shell         utils.CachedData[ShellType]
 	TerminalWidth utils.CachedData[int]
+	User          utils.CachedData[string]
+	Hostname      utils.CachedData[string]
+	HomeDir       utils.CachedData[string]
+	Time          utils.CachedData[time.Time]
+
+	gitCacheMaxAge time.Duration
+	gitStatus      bool
+	gitDescribe    bool
+	vcsStatus      bool
+	pipefail       bool
+	problemHandler func(error)
+}
+
+// Provider is the name of a piece of context that modules can depend on
+type Provider string
+
+const (
+	ProviderExitCode      Provider = "exit_code"
+	ProviderCmdDuration   Provider = "cmd_duration"
+	ProviderJobs          Provider = "jobs"
+	ProviderCWD           Provider = "cwd"
+	ProviderGit           Provider = "git"
+	ProviderGitStatus     Provider = "git_status"
+	ProviderGitDescribe   Provider = "git_describe"
+	ProviderVCS           Provider = "vcs"
+	ProviderVCSStatus     Provider = "vcs_status"
+	ProviderOS            Provider = "os"
+	ProviderShell         Provider = "shell"
+	ProviderTerminalWidth Provider = "terminal_width"
+	ProviderUser          Provider = "user"
+	ProviderHostname      Provider = "hostname"
+	ProviderHomeDir       Provider = "home_dir"
+	ProviderTime          Provider = "time"
+)
+
+var AllProviders = []Provider{
+	ProviderExitCode,
+	ProviderCmdDuration,
+	ProviderJobs,
+	ProviderCWD,
+	ProviderGit,
+	ProviderGitStatus,
+	ProviderGitDescribe,
+	ProviderVCS,
+	ProviderVCSStatus,
+	ProviderOS,
+	ProviderShell,
+	ProviderTerminalWidth,
+	ProviderUser,
+	ProviderHostname,
+	ProviderHomeDir,
+	ProviderTime,
 }
 
 type ShellType int
@@ -25,56 +90,543 @@ type ShellType int
 const (
 	ShellBash ShellType = iota
 	ShellZsh
+	ShellFish
+	ShellPwsh
+	ShellNu
 	ShellOther
 )
 
-func GetApplicationContext(shell string, exitCode int) *ApplicationContext {
-	context := ApplicationContext{}
+func (s ShellType) String() string {
+	switch s {
+	case ShellBash:
+		return "bash"
+	case ShellZsh:
+		return "zsh"
+	case ShellFish:
+		return "fish"
+	case ShellPwsh:
+		return "pwsh"
+	case ShellNu:
+		return "nu"
+	default:
+		return "other"
+	}
+}
+
+func (s ShellType) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *ShellType) UnmarshalText(text []byte) error {
+	*s = GetShellType(string(text))
+	return nil
+}
+
+// ShellValues are the values passed on the command line by the shell integration
+type ShellValues struct {
+	Shell    string
+	ExitCode int
+	// Exit codes of the commands of the previous pipeline, the exit code alone if empty
+	PipeStatus []int
+	// Time at which the previous command started, zero if unknown
+	CommandStart time.Time
+	// Duration of the previous command measured by the shell (e.g. $CMD_DURATION of fish), used instead of CommandStart
+	CmdDuration time.Duration
+	// Number of background and stopped jobs of the shell
+	Jobs int
+}
+
+func GetApplicationContext(values ShellValues) *ApplicationContext {
+	context := ApplicationContext{CreatedAt: time.Now()}
+
+	context.CWD = utils.NewCachedDataWithError(context.getCWD, "cwd")
+
+	context.GitContext = utils.NewCachedData(context.getGitContext, "git repo")
+	context.GitContext.SetFallback(context.getStaleGitContext)
 
-	context.GitContext = utils.NewCachedData(gitcontext.GetGitState, "git repo")
+	context.VCSContext = utils.NewCachedData(context.getVCSContext, "vcs repo")
+	context.VCSContext.SetFallback(context.getStaleVCSContext)
 
-	context.ExitCode = utils.NewCachedData(func(result chan int) { result <- exitCode }, "exit code")
+	context.ExitCode = utils.NewCachedData(func(result chan int) { result <- values.ExitCode }, "exit code")
+	context.PipeStatus = utils.NewCachedData(func(result chan []int) { result <- getPipeStatus(values.PipeStatus, values.ExitCode) }, "pipestatus")
+	context.CmdDuration = utils.NewCachedData(func(result chan time.Duration) { result <- getCmdDuration(values, context.CreatedAt) }, "command duration")
+	context.Jobs = utils.NewCachedData(func(result chan int) { result <- values.Jobs }, "jobs")
 
-	context.OS = utils.NewCachedData(oscontext.GetOS, "os")
-	context.TerminalWidth = utils.NewCachedData(context.getTerminalWidth, "terminal width")
+	context.OS = utils.NewCachedData(context.getOS, "os")
+	context.OS.SetFallback(context.getStaleOS)
+	context.TerminalWidth = utils.NewCachedDataWithError(context.getTerminalWidth, "terminal width")
 
-	context.CWD = utils.NewCachedData(context.getCWD, "cwd")
+	context.Shell = utils.NewCachedData(func(shellType chan ShellType) { shellType <- GetShellType(values.Shell) }, "shell")
 
-	context.Shell = utils.NewCachedData(func(shellType chan ShellType) { shellType <- context.getShell(shell) }, "shell")
+	context.User = utils.NewCachedData(func(result chan string) { result <- os.Getenv("USER") }, "user")
+	context.Hostname = utils.NewCachedDataWithError(context.getHostname, "hostname")
+	context.HomeDir = utils.NewCachedDataWithError(context.getHomeDir, "home dir")
+	context.Time = utils.NewCachedData(func(result chan time.Time) { result <- time.Now() }, "time")
 
 	return &context
 }
 
-/*
- * Context cached data getters
- */
+// Start starts fetching the values of the providers in the background.
+// Providers which are not started are fetched when they are first read.
+// The state of the git working tree and the nearest tag are only read if the git_status and git_describe
+// providers are started with the git provider, and the state of the working tree of other version control systems
+// only if the vcs_status provider is started.
+func (context *ApplicationContext) Start(providers ...Provider) {
+	if slices.Contains(providers, ProviderGitStatus) {
+		context.gitStatus = true
+	}
+	if slices.Contains(providers, ProviderVCSStatus) {
+		// The vcs providers share the git context in git repositories
+		context.vcsStatus = true
+		context.gitStatus = true
+	}
+	if slices.Contains(providers, ProviderGitDescribe) {
+		context.gitDescribe = true
+	}
+	for _, provider := range providers {
+		switch provider {
+		case ProviderExitCode:
+			context.ExitCode.Start()
+			context.PipeStatus.Start()
+		case ProviderCmdDuration:
+			context.CmdDuration.Start()
+		case ProviderJobs:
+			context.Jobs.Start()
+		case ProviderCWD:
+			context.CWD.Start()
+		case ProviderGit, ProviderGitStatus, ProviderGitDescribe:
+			context.GitContext.Start()
+		case ProviderVCS, ProviderVCSStatus:
+			context.VCSContext.Start()
+		case ProviderOS:
+			context.OS.Start()
+		case ProviderShell:
+			context.Shell.Start()
+		case ProviderTerminalWidth:
+			context.TerminalWidth.Start()
+		case ProviderUser:
+			context.User.Start()
+		case ProviderHostname:
+			context.Hostname.Start()
+		case ProviderHomeDir:
+			context.HomeDir.Start()
+		case ProviderTime:
+			context.Time.Start()
+		default:
+			log.Warn().Msgf("Unknown context provider: %s", provider)
+		}
+	}
+}
+
+// SetTimeout limits the time to wait for a provider, counted from the moment it is started
+func (context *ApplicationContext) SetTimeout(provider Provider, timeout time.Duration) {
+	switch provider {
+	case ProviderExitCode:
+		context.ExitCode.SetTimeout(timeout)
+		context.PipeStatus.SetTimeout(timeout)
+	case ProviderCmdDuration:
+		context.CmdDuration.SetTimeout(timeout)
+	case ProviderJobs:
+		context.Jobs.SetTimeout(timeout)
+	case ProviderCWD:
+		context.CWD.SetTimeout(timeout)
+	case ProviderGit, ProviderGitStatus, ProviderGitDescribe:
+		context.GitContext.SetTimeout(timeout)
+	case ProviderVCS, ProviderVCSStatus:
+		context.VCSContext.SetTimeout(timeout)
+	case ProviderOS:
+		context.OS.SetTimeout(timeout)
+	case ProviderShell:
+		context.Shell.SetTimeout(timeout)
+	case ProviderTerminalWidth:
+		context.TerminalWidth.SetTimeout(timeout)
+	case ProviderUser:
+		context.User.SetTimeout(timeout)
+	case ProviderHostname:
+		context.Hostname.SetTimeout(timeout)
+	case ProviderHomeDir:
+		context.HomeDir.SetTimeout(timeout)
+	case ProviderTime:
+		context.Time.SetTimeout(timeout)
+	default:
+		log.Warn().Msgf("Unknown context provider: %s", provider)
+	}
+}
+
+// SetGitCache enables the on-disk cache of the git context, reusing entries younger than maxAge.
+// It must be called before the git provider is started.
+func (context *ApplicationContext) SetGitCache(maxAge time.Duration) {
+	context.gitCacheMaxAge = maxAge
+}
+
+// SetPipefail makes a failure of any command of the previous pipeline count as a failure, like "set -o pipefail"
+func (context *ApplicationContext) SetPipefail(enabled bool) {
+	context.pipefail = enabled
+}
+
+// SetProblemHandler sets the function receiving the problems found in the config and in the plugins, instead of printing them
+func (context *ApplicationContext) SetProblemHandler(handler func(error)) {
+	context.problemHandler = handler
+}
+
+// ReportProblem reports a problem which doesn't stop the prompt, like an invalid value replaced by its default.
+// Without a handler, the problem is printed on stderr.
+func (context *ApplicationContext) ReportProblem(problem error) {
+	if context != nil && context.problemHandler != nil {
+		context.problemHandler(problem)
+		return
+	}
+	fmt.Fprintln(os.Stderr, "promptorium:", problem)
+}
+
+// Failed reports whether the previous command failed. With pipefail, it also reports a failure of any command of the pipeline.
+func (context *ApplicationContext) Failed() bool {
+	if context.ExitCode.GetContent() != 0 {
+		return true
+	}
+	if !context.pipefail {
+		return false
+	}
+	for _, code := range context.PipeStatus.GetContent() {
+		if code != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// SetDeadline sets the point in time after which the prompt stops waiting for any provider
+func (context *ApplicationContext) SetDeadline(deadline time.Time) {
+	context.ExitCode.SetDeadline(deadline)
+	context.PipeStatus.SetDeadline(deadline)
+	context.CmdDuration.SetDeadline(deadline)
+	context.Jobs.SetDeadline(deadline)
+	context.CWD.SetDeadline(deadline)
+	context.GitContext.SetDeadline(deadline)
+	context.VCSContext.SetDeadline(deadline)
+	context.OS.SetDeadline(deadline)
+	context.Shell.SetDeadline(deadline)
+	context.TerminalWidth.SetDeadline(deadline)
+	context.User.SetDeadline(deadline)
+	context.Hostname.SetDeadline(deadline)
+	context.HomeDir.SetDeadline(deadline)
+	context.Time.SetDeadline(deadline)
+}
+
+// TimedOut reports whether any of the providers did not return its value in time
+func (context *ApplicationContext) TimedOut(providers ...Provider) bool {
+	for _, provider := range providers {
+		timedOut := false
+		switch provider {
+		case ProviderExitCode:
+			timedOut = context.ExitCode.TimedOut() || context.PipeStatus.TimedOut()
+		case ProviderCmdDuration:
+			timedOut = context.CmdDuration.TimedOut()
+		case ProviderJobs:
+			timedOut = context.Jobs.TimedOut()
+		case ProviderCWD:
+			timedOut = context.CWD.TimedOut()
+		case ProviderGit, ProviderGitStatus, ProviderGitDescribe:
+			timedOut = context.GitContext.TimedOut()
+		case ProviderVCS, ProviderVCSStatus:
+			timedOut = context.VCSContext.TimedOut()
+		case ProviderOS:
+			timedOut = context.OS.TimedOut()
+		case ProviderShell:
+			timedOut = context.Shell.TimedOut()
+		case ProviderTerminalWidth:
+			timedOut = context.TerminalWidth.TimedOut()
+		case ProviderUser:
+			timedOut = context.User.TimedOut()
+		case ProviderHostname:
+			timedOut = context.Hostname.TimedOut()
+		case ProviderHomeDir:
+			timedOut = context.HomeDir.TimedOut()
+		case ProviderTime:
+			timedOut = context.Time.TimedOut()
+		}
+		if timedOut {
+			return true
+		}
+	}
+	return false
+}
+
+// Snapshot holds the values of an ApplicationContext
+type Snapshot struct {
+	ExitCode      int                   `json:"exit_code"`
+	PipeStatus    []int                 `json:"pipestatus"`
+	CmdDuration   time.Duration         `json:"cmd_duration"`
+	Jobs          int                   `json:"jobs"`
+	CWD           string                `json:"cwd"`
+	GitContext    gitcontext.GitContext `json:"git"`
+	GitRoot       string                `json:"git_root"`
+	VCSContext    vcscontext.VCSContext `json:"vcs"`
+	OS            oscontext.OS          `json:"os"`
+	Shell         ShellType             `json:"shell"`
+	TerminalWidth int                   `json:"terminal_width"`
+	User          string                `json:"user"`
+	Hostname      string                `json:"hostname"`
+	HomeDir       string                `json:"home_dir"`
+	Time          time.Time             `json:"time"`
+}
+
+// Snapshot resolves all the values of the context
+func (context *ApplicationContext) Snapshot() Snapshot {
+	context.Start(AllProviders...)
+	snapshot := Snapshot{
+		ExitCode:      context.ExitCode.GetContent(),
+		PipeStatus:    context.PipeStatus.GetContent(),
+		CmdDuration:   context.CmdDuration.GetContent(),
+		Jobs:          context.Jobs.GetContent(),
+		CWD:           context.CWD.GetContent(),
+		GitContext:    context.GitContext.GetContent(),
+		VCSContext:    context.VCSContext.GetContent(),
+		OS:            context.OS.GetContent(),
+		Shell:         context.Shell.GetContent(),
+		TerminalWidth: context.TerminalWidth.GetContent(),
+		User:          context.User.GetContent(),
+		Hostname:      context.Hostname.GetContent(),
+		HomeDir:       context.HomeDir.GetContent(),
+		Time:          context.Time.GetContent(),
+	}
+	if snapshot.GitContext.IsGitRepo && snapshot.GitContext.GitRoot != nil {
+		snapshot.GitRoot = snapshot.GitContext.GitRoot()
+	}
+	return snapshot
+}
+
+// ReadSnapshot reads a snapshot from a JSON file, as written by "promptorium context dump"
+func ReadSnapshot(path string) (Snapshot, error) {
+	snapshot := Snapshot{}
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return snapshot, err
+	}
+	err = json.Unmarshal(file, &snapshot)
+	return snapshot, err
+}
 
-func (context *ApplicationContext) getShell(shell string) ShellType {
+// NewApplicationContextFromSnapshot returns an ApplicationContext which uses the values of the snapshot instead of probing the system
+func NewApplicationContextFromSnapshot(snapshot Snapshot) *ApplicationContext {
+	context := ApplicationContext{CreatedAt: time.Now()}
+
+	gitContext := snapshot.GitContext
+	gitRoot := snapshot.GitRoot
+	gitContext.GitRoot = func() string { return gitRoot }
+
+	context.GitContext = utils.NewCachedData(func(result chan gitcontext.GitContext) { result <- gitContext }, "git repo")
+	context.VCSContext = utils.NewCachedData(func(result chan vcscontext.VCSContext) { result <- snapshot.VCSContext }, "vcs repo")
+	context.ExitCode = utils.NewCachedData(func(result chan int) { result <- snapshot.ExitCode }, "exit code")
+	context.PipeStatus = utils.NewCachedData(func(result chan []int) { result <- getPipeStatus(snapshot.PipeStatus, snapshot.ExitCode) }, "pipestatus")
+	context.CmdDuration = utils.NewCachedData(func(result chan time.Duration) { result <- snapshot.CmdDuration }, "command duration")
+	context.Jobs = utils.NewCachedData(func(result chan int) { result <- snapshot.Jobs }, "jobs")
+	context.OS = utils.NewCachedData(func(result chan oscontext.OS) { result <- snapshot.OS }, "os")
+	context.TerminalWidth = utils.NewCachedData(func(result chan int) { result <- snapshot.TerminalWidth }, "terminal width")
+	context.CWD = utils.NewCachedData(func(result chan string) { result <- snapshot.CWD }, "cwd")
+	context.Shell = utils.NewCachedData(func(result chan ShellType) { result <- snapshot.Shell }, "shell")
+	context.User = utils.NewCachedData(func(result chan string) { result <- snapshot.User }, "user")
+	context.Hostname = utils.NewCachedData(func(result chan string) { result <- snapshot.Hostname }, "hostname")
+	context.HomeDir = utils.NewCachedData(func(result chan string) { result <- snapshot.HomeDir }, "home dir")
+	context.Time = utils.NewCachedData(func(result chan time.Time) { result <- snapshot.Time }, "time")
+
+	return &context
+}
+
+// GetShellType returns the ShellType corresponding to the name or path of a shell
+func GetShellType(shell string) ShellType {
 	shell = filepath.Base(shell)
 	switch shell {
 	case "bash":
 		return ShellBash
 	case "zsh":
 		return ShellZsh
+	case "fish":
+		return ShellFish
+	case "pwsh":
+		return ShellPwsh
+	case "nu":
+		return ShellNu
 	default:
 		return ShellOther
 	}
 }
 
-func (context *ApplicationContext) getTerminalWidth(result chan int) {
-	terminalWidth, _, error := term.GetSize(0)
-	if error != nil {
-		log.Warn().Msg("Error getting terminal width")
-		terminalWidth = 0
+/*
+ * Context cached data getters
+ */
+
+// ParsePipeStatus parses the exit codes of a pipeline, separated by spaces (e.g. "${PIPESTATUS[*]}")
+func ParsePipeStatus(raw string) ([]int, error) {
+	result := []int{}
+	for _, field := range strings.Fields(raw) {
+		code, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, code)
+	}
+	return result, nil
+}
+
+func getPipeStatus(pipeStatus []int, exitCode int) []int {
+	if len(pipeStatus) == 0 {
+		return []int{exitCode}
+	}
+	return pipeStatus
+}
+
+// ParseCommandStart parses the start time of a command, given as seconds since the epoch with an optional fraction
+// (e.g. $EPOCHREALTIME). An empty value is a zero time.
+func ParseCommandStart(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	// $EPOCHREALTIME uses the decimal separator of the locale
+	seconds, err := strconv.ParseFloat(strings.Replace(raw, ",", ".", 1), 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), nil
+}
+
+// Returns the duration measured by the shell if any. Otherwise returns the time elapsed between the start of the previous
+// command and the start of promptorium, which includes the time the shell takes to run promptorium.
+// The duration is zero if the start time is unknown.
+func getCmdDuration(values ShellValues, now time.Time) time.Duration {
+	if values.CmdDuration > 0 {
+		return values.CmdDuration
+	}
+	start := values.CommandStart
+	if start.IsZero() || start.After(now) {
+		return 0
+	}
+	return now.Sub(start)
+}
+
+func (context *ApplicationContext) getTerminalWidth() (int, error) {
+	terminalWidth, _, err := term.GetSize(0)
+	return terminalWidth, err
+}
+
+func (context *ApplicationContext) getCWD() (string, error) {
+	return os.Getwd()
+}
+
+func (context *ApplicationContext) getHostname() (string, error) {
+	return os.Hostname()
+}
+
+func (context *ApplicationContext) getHomeDir() (string, error) {
+	return os.UserHomeDir()
+}
+
+// Gets the git context and stores it as the last known git context of the repository
+func (context *ApplicationContext) getGitContext(result chan gitcontext.GitContext) {
+	value := context.readGitContext()
+	if context.gitDescribe && value.IsGitRepo {
+		gitcontext.ReadDescribe(context.CWD.GetContent(), &value)
+	}
+	if key, ok := context.getGitStaleKey(); ok {
+		saveStale(key, value)
+	}
+	result <- value
+}
+
+// Without the git_status provider, only the metadata which doesn't require running git is read.
+// Otherwise, the daemon is queried first, and git is run directly if it is not running.
+func (context *ApplicationContext) readGitContext() gitcontext.GitContext {
+	if !context.gitStatus {
+		return gitcontext.ReadGitContext(context.CWD.GetContent())
+	}
+	if value, ok := daemon.GetGitContext(context.CWD.GetContent()); ok {
+		return value
 	}
-	result <- terminalWidth
+	gitContext := make(chan gitcontext.GitContext, 1)
+	if context.gitCacheMaxAge > 0 {
+		go gitcontext.GetCachedGitState(gitContext, context.gitCacheMaxAge)
+	} else {
+		go gitcontext.GetGitState(gitContext)
+	}
+	return <-gitContext
 }
 
-func (context *ApplicationContext) getCWD(result chan string) {
-	cwd, error := os.Getwd()
-	if error != nil {
-		log.Warn().Msg("Error getting CWD")
-		cwd = ""
+// Returns the last known git context of the current directory
+func (context *ApplicationContext) getStaleGitContext() (gitcontext.GitContext, bool) {
+	key, ok := context.getGitStaleKey()
+	if !ok {
+		return gitcontext.GitContext{}, false
+	}
+	gitContext, ok := loadStale[gitcontext.GitContext](key)
+	if !ok {
+		return gitContext, false
 	}
-	result <- cwd
+	gitRoot := gitcontext.FindGitRoot(context.CWD.GetContent())
+	gitContext.GitRoot = func() string { return gitRoot }
+	return gitContext, true
+}
+
+// The last known git context is stored per repository, there is none outside of the repositories
+func (context *ApplicationContext) getGitStaleKey() (string, bool) {
+	root := gitcontext.FindGitRoot(context.CWD.GetContent())
+	if root == "" {
+		return "", false
+	}
+	// The context read without git status is stored separately, so that it doesn't replace a context with the status
+	if !context.gitStatus {
+		return "git-metadata-" + hashKey(root), true
+	}
+	return "git-" + hashKey(root), true
+}
+
+// Gets the context of the repository containing the current directory, whatever its version control system,
+// and stores it as the last known context of the repository
+func (context *ApplicationContext) getVCSContext(result chan vcscontext.VCSContext) {
+	value := vcscontext.VCSContext{}
+	provider, root, ok := vcscontext.FindProvider(context.CWD.GetContent())
+	switch {
+	case !ok:
+		log.Trace().Msgf("No repository found in %s", context.CWD.GetContent())
+	case provider.Name() == vcscontext.VCSGit:
+		// Git repositories share the git context, which is read from the daemon or the cache when possible
+		value = vcscontext.FromGitContext(context.GitContext.GetContent())
+	default:
+		log.Trace().Msgf("Found %s repository: %s", provider.Name(), root)
+		value = provider.Read(root, context.vcsStatus)
+	}
+	if ok {
+		saveStale(getVCSStaleKey(root), value)
+	}
+	result <- value
+}
+
+// Returns the last known context of the repository containing the current directory
+func (context *ApplicationContext) getStaleVCSContext() (vcscontext.VCSContext, bool) {
+	_, root, ok := vcscontext.FindProvider(context.CWD.GetContent())
+	if !ok {
+		return vcscontext.VCSContext{}, false
+	}
+	return loadStale[vcscontext.VCSContext](getVCSStaleKey(root))
+}
+
+func getVCSStaleKey(root string) string {
+	return "vcs-" + hashKey(root)
+}
+
+// Gets the OS, from the daemon if it is running, and stores it as the last known OS
+func (context *ApplicationContext) getOS(result chan oscontext.OS) {
+	if value, ok := daemon.GetOS(); ok {
+		saveStale("os", value)
+		result <- value
+		return
+	}
+	osContext := make(chan oscontext.OS, 1)
+	go oscontext.GetOS(osContext)
+	value := <-osContext
+	saveStale("os", value)
+	result <- value
+}
+
+func (context *ApplicationContext) getStaleOS() (oscontext.OS, bool) {
+	return loadStale[oscontext.OS]("os")
 }
@@ -1,66 +1,134 @@
 package oscontext
 
 import (
-	"fmt"
-	"os/exec"
+	"bufio"
+	"os"
+	"regexp"
+	"runtime"
 	"strings"
 )
 
-type OS int
+// OS identifies the operating system, and the distribution on Linux, as described by os-release
+type OS struct {
+	// Lowercase identifier of the operating system or distribution, e.g. ubuntu, fedora, macos
+	ID string `json:"id"`
+	// Identifiers of the distributions this one is derived from, closest first, e.g. ubuntu debian for Linux Mint
+	IDLike     []string `json:"id_like"`
+	Name       string   `json:"name"`
+	VersionID  string   `json:"version_id"`
+	PrettyName string   `json:"pretty_name"`
+}
 
-const (
-	OSLinux OS = iota
-	OSMac
-	OSFedora
-	OSUbuntu
-	OSDebian
-	OSArch
-	OSOther
-)
+var OS_RELEASE_PATHS = []string{"/etc/os-release", "/usr/lib/os-release"}
 
-func GetOS(result chan OS) {
+var MACOS_VERSION_PATH = "/System/Library/CoreServices/SystemVersion.plist"
 
-	output, err := exec.Command("uname", "-s").Output()
-	if err != nil {
-		result <- OSOther
+// GetOS reads the operating system. On Linux and the BSDs, the os-release file is parsed.
+func GetOS(result chan OS) {
+	switch runtime.GOOS {
+	case "darwin":
+		result <- getMacOS()
 		return
-	}
-	os := strings.Replace(string(output), "\n", "", -1)
-	if os == "" {
-		result <- OSOther
+	case "windows":
+		result <- OS{ID: "windows", Name: "Windows", PrettyName: "Windows"}
 		return
 	}
-	// Return directly if not Linux
-	if os != "Linux" {
-		result <- OSOther
-		return
+
+	for _, path := range OS_RELEASE_PATHS {
+		osRelease, err := ParseOSRelease(path)
+		if err == nil {
+			result <- osRelease
+			return
+		}
 	}
-	// Get Linux distribution
-	output, err = exec.Command("cat", "/etc/os-release").Output()
+	result <- OS{ID: runtime.GOOS, Name: runtime.GOOS, PrettyName: runtime.GOOS}
+}
+
+// ParseOSRelease parses an os-release file.
+// See https://www.freedesktop.org/software/systemd/man/latest/os-release.html
+func ParseOSRelease(path string) (OS, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		fmt.Println("Error getting Linux distribution:", err)
-		result <- OSOther
-		return
+		return OS{}, err
 	}
-	entries := strings.Split(string(output), "\n")
-	for _, entry := range entries {
-		if strings.HasPrefix(entry, "ID=") {
-			os = strings.Split(entry, "=")[1]
-			break
+	defer file.Close()
+
+	values := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
 		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		values[key] = parseOSReleaseValue(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return OS{}, err
+	}
+
+	// The defaults of the specification
+	result := OS{
+		ID:         strings.ToLower(values["ID"]),
+		IDLike:     strings.Fields(strings.ToLower(values["ID_LIKE"])),
+		Name:       values["NAME"],
+		VersionID:  values["VERSION_ID"],
+		PrettyName: values["PRETTY_NAME"],
 	}
-	switch os {
-	case "arch":
-		result <- OSArch
-	case "debian":
-		result <- OSDebian
-	case "fedora":
-		result <- OSFedora
-	case "macos":
-		result <- OSMac
-	case "ubuntu":
-		result <- OSUbuntu
-	default:
-		result <- OSLinux
+	if result.ID == "" {
+		result.ID = "linux"
+	}
+	if result.Name == "" {
+		result.Name = "Linux"
+	}
+	if result.PrettyName == "" {
+		result.PrettyName = result.Name
+	}
+	return result, nil
+}
+
+// Returns a value without its quotes and escapes
+func parseOSReleaseValue(value string) string {
+	if len(value) < 2 {
+		return value
+	}
+	switch value[0] {
+	case '\'':
+		return strings.TrimSuffix(value[1:], "'")
+	case '"':
+		value = strings.TrimSuffix(value[1:], `"`)
+		return strings.NewReplacer(`\"`, `"`, `\\`, `\`, "\\`", "`", `\$`, `$`).Replace(value)
+	}
+	return value
+}
+
+var macOSVersionPattern = regexp.MustCompile(`<key>ProductVersion</key>\s*<string>([^<]*)</string>`)
+
+// Reads the version of macOS from the system version file, which avoids running sw_vers
+func getMacOS() OS {
+	result := OS{ID: "macos", Name: "macOS", PrettyName: "macOS"}
+	file, err := os.ReadFile(MACOS_VERSION_PATH)
+	if err != nil {
+		return result
+	}
+	if match := macOSVersionPattern.FindSubmatch(file); match != nil {
+		result.VersionID = string(match[1])
+		result.PrettyName = "macOS " + result.VersionID
+	}
+	return result
+}
+
+// IDs returns the identifier of the OS followed by the ones it is derived from, to look up values by closest match
+func (os OS) IDs() []string {
+	return append([]string{os.ID}, os.IDLike...)
+}
+
+func (os OS) String() string {
+	if os.ID == "" {
+		return "other"
 	}
+	return os.ID
 }
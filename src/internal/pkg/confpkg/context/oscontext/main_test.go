@@ -0,0 +1,112 @@
+package oscontext
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseOSRelease(t *testing.T) {
+	tests := []struct {
+		name      string
+		osRelease string
+		want      OS
+	}{
+		{
+			name: "quoted values",
+			osRelease: `PRETTY_NAME="Ubuntu 24.04 LTS"
+NAME="Ubuntu"
+VERSION_ID="24.04"
+ID=ubuntu
+ID_LIKE=debian
+`,
+			want: OS{ID: "ubuntu", IDLike: []string{"debian"}, Name: "Ubuntu", VersionID: "24.04", PrettyName: "Ubuntu 24.04 LTS"},
+		},
+		{
+			name: "unquoted and single quoted values",
+			osRelease: `NAME=Fedora
+ID=fedora
+VERSION_ID=40
+PRETTY_NAME='Fedora Linux 40'
+`,
+			want: OS{ID: "fedora", IDLike: []string{}, Name: "Fedora", VersionID: "40", PrettyName: "Fedora Linux 40"},
+		},
+		{
+			name: "escapes",
+			osRelease: `NAME="Test \"OS\" \$HOME \\ \` + "`" + `"
+ID=test
+`,
+			want: OS{ID: "test", IDLike: []string{}, Name: "Test \"OS\" $HOME \\ `", PrettyName: "Test \"OS\" $HOME \\ `"},
+		},
+		{
+			name: "comments and blank lines",
+			osRelease: `# This is a comment
+ID=arch
+
+   # Indented comment
+NAME="Arch Linux"
+not a variable
+`,
+			want: OS{ID: "arch", IDLike: []string{}, Name: "Arch Linux", PrettyName: "Arch Linux"},
+		},
+		{
+			name: "several ID_LIKE, lowercased",
+			osRelease: `ID=LinuxMint
+ID_LIKE="Ubuntu Debian"
+NAME="Linux Mint"
+`,
+			want: OS{ID: "linuxmint", IDLike: []string{"ubuntu", "debian"}, Name: "Linux Mint", PrettyName: "Linux Mint"},
+		},
+		{
+			name:      "missing ID and NAME",
+			osRelease: "ID_LIKE=rhel\n",
+			want:      OS{ID: "linux", IDLike: []string{"rhel"}, Name: "Linux", PrettyName: "Linux"},
+		},
+		{
+			name:      "empty file",
+			osRelease: "",
+			want:      OS{ID: "linux", IDLike: []string{}, Name: "Linux", PrettyName: "Linux"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "os-release")
+			err := os.WriteFile(path, []byte(test.osRelease), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseOSRelease(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseOSReleaseMissingFile(t *testing.T) {
+	_, err := ParseOSRelease(filepath.Join(t.TempDir(), "os-release"))
+	if err == nil {
+		t.Error("got no error for a missing file")
+	}
+}
+
+func TestIDs(t *testing.T) {
+	tests := []struct {
+		os   OS
+		want []string
+	}{
+		{OS{ID: "ubuntu"}, []string{"ubuntu"}},
+		{OS{ID: "linuxmint", IDLike: []string{"ubuntu", "debian"}}, []string{"linuxmint", "ubuntu", "debian"}},
+		{OS{ID: "linux", IDLike: []string{"rhel"}}, []string{"linux", "rhel"}},
+	}
+	for _, test := range tests {
+		got := test.os.IDs()
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%+v.IDs() = %v, want %v", test.os, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package context
+
+// Last known values of the providers, used as fallback when a provider doesn't return in time.
+// They are stored as JSON files in the promptorium cache directory, one per repository for the git and vcs providers.
+// A file is only written when its value changes, and the files which haven't been written for STALE_MAX_AGE are removed.
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"promptorium/internal/log"
+	"promptorium/internal/utils"
+	"time"
+)
+
+var STALE_MAX_AGE = 30 * 24 * time.Hour
+
+func getStaleDir() string {
+	return filepath.Join(utils.GetCacheDir(), "stale")
+}
+
+func getStalePath(key string) string {
+	return filepath.Join(getStaleDir(), key+".json")
+}
+
+func loadStale[T any](key string) (T, bool) {
+	var value T
+	file, err := os.ReadFile(getStalePath(key))
+	if err != nil {
+		log.Trace().Msgf("No last known value for %s", key)
+		return value, false
+	}
+	err = json.Unmarshal(file, &value)
+	if err != nil {
+		log.Trace().Msgf("Could not parse last known value for %s: %s", key, err)
+		return value, false
+	}
+	log.Trace().Msgf("Using last known value for %s", key)
+	return value, true
+}
+
+func saveStale[T any](key string, value T) {
+	file, err := json.Marshal(value)
+	if err != nil {
+		log.Trace().Msgf("Could not encode last known value for %s: %s", key, err)
+		return
+	}
+	// Most prompts show the same values as the previous one, in which case nothing is written
+	current, err := os.ReadFile(getStalePath(key))
+	if err == nil && bytes.Equal(current, file) {
+		return
+	}
+	err = utils.WriteFileAtomic(getStalePath(key), file)
+	if err != nil {
+		log.Trace().Msgf("Could not save last known value for %s: %s", key, err)
+		return
+	}
+	pruneStale()
+}
+
+// Removes the last known values which haven't changed for STALE_MAX_AGE, e.g. the ones of deleted repositories
+func pruneStale() {
+	files, err := os.ReadDir(getStaleDir())
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		info, err := file.Info()
+		if err == nil && time.Since(info.ModTime()) > STALE_MAX_AGE {
+			log.Trace().Msgf("Removing last known value %s", file.Name())
+			os.Remove(filepath.Join(getStaleDir(), file.Name()))
+		}
+	}
+}
+
+func hashKey(key string) string {
+	hash := sha1.Sum([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,53 @@
+package vcscontext
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// FossilProvider reads Fossil checkouts with fossil. Ahead and behind are not supported,
+// as they require contacting the remote repository.
+type FossilProvider struct{}
+
+func (FossilProvider) Name() VCS {
+	return VCSFossil
+}
+
+func (FossilProvider) IsRoot(dir string) bool {
+	// Checkouts are marked by .fslckout, or _FOSSIL_ on older versions and on Windows
+	return exists(filepath.Join(dir, ".fslckout")) || exists(filepath.Join(dir, "_FOSSIL_"))
+}
+
+func (FossilProvider) Read(root string, status bool) VCSContext {
+	result := VCSContext{VCS: VCSFossil, IsRepo: true, Root: root}
+	output, err := run(root, "fossil", "branch", "current")
+	if err != nil {
+		return VCSContext{}
+	}
+	result.Branch = strings.TrimSpace(output)
+	if !status {
+		return result
+	}
+
+	output, err = run(root, "fossil", "changes")
+	if err == nil {
+		for _, line := range lines(output) {
+			change, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+			switch change {
+			case "ADDED", "DELETED", "RENAMED", "ADDED_BY_MERGE", "ADDED_BY_INTEGRATE":
+				result.StagedChanges++
+			case "CONFLICT":
+				result.ConflictedFiles++
+			default:
+				// EDITED, MISSING, UPDATED_BY_MERGE...
+				result.UnstagedChanges++
+			}
+		}
+	}
+	output, err = run(root, "fossil", "extras")
+	if err == nil {
+		result.UntrackedFiles = len(lines(output))
+	}
+	setDirty(&result)
+	return result
+}
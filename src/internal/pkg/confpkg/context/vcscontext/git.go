@@ -0,0 +1,52 @@
+package vcscontext
+
+import (
+	"path/filepath"
+	"promptorium/internal/pkg/confpkg/context/gitcontext"
+)
+
+// GitProvider reads git repositories with gitcontext
+type GitProvider struct{}
+
+func (GitProvider) Name() VCS {
+	return VCSGit
+}
+
+func (GitProvider) IsRoot(dir string) bool {
+	return exists(filepath.Join(dir, ".git"))
+}
+
+func (GitProvider) Read(root string, status bool) VCSContext {
+	if !status {
+		return FromGitContext(gitcontext.ReadGitContext(root))
+	}
+	gitContext := make(chan gitcontext.GitContext, 1)
+	gitcontext.GetGitStateInDir(root, gitContext)
+	return FromGitContext(<-gitContext)
+}
+
+// FromGitContext converts a git context, so that it can be shared by the git and vcs modules
+func FromGitContext(gitContext gitcontext.GitContext) VCSContext {
+	if !gitContext.IsGitRepo {
+		return VCSContext{}
+	}
+	result := VCSContext{
+		VCS:             VCSGit,
+		IsRepo:          true,
+		Branch:          gitContext.LocalBranch,
+		Commit:          gitContext.ShortCommit,
+		HasUpstream:     gitContext.HasUpstream,
+		Ahead:           gitContext.Ahead,
+		Behind:          gitContext.Behind,
+		IsDirty:         gitContext.IsDirty,
+		StagedChanges:   gitContext.StagedChanges,
+		UnstagedChanges: gitContext.UnstagedChanges,
+		UntrackedFiles:  gitContext.UntrackedFiles,
+		ConflictedFiles: gitContext.ConflictedFiles,
+		Stashes:         gitContext.Stashes,
+	}
+	if gitContext.GitRoot != nil {
+		result.Root = gitContext.GitRoot()
+	}
+	return result
+}
@@ -0,0 +1,74 @@
+package vcscontext
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// JujutsuProvider reads Jujutsu repositories with jj. The branch is the closest bookmark of the working copy,
+// and ahead and behind are counted against the bookmark of the same name on origin.
+// Jujutsu has no staging area and tracks new files automatically, so all the changes are unstaged.
+type JujutsuProvider struct{}
+
+func (JujutsuProvider) Name() VCS {
+	return VCSJujutsu
+}
+
+func (JujutsuProvider) IsRoot(dir string) bool {
+	return isDir(filepath.Join(dir, ".jj"))
+}
+
+func (JujutsuProvider) Read(root string, status bool) VCSContext {
+	result := VCSContext{VCS: VCSJujutsu, IsRepo: true, Root: root}
+	args := []string{"log", "--no-graph", "--color", "never", "-r", "@", "-T", `change_id.shortest(8) ++ "\n"`}
+	// Without --ignore-working-copy, jj snapshots the working copy, which is only needed for the status
+	if !status {
+		args = append(args, "--ignore-working-copy")
+	}
+	output, err := run(root, "jj", args...)
+	if err != nil {
+		return VCSContext{}
+	}
+	result.Commit = strings.TrimSpace(output)
+
+	output, err = run(root, "jj", "log", "--ignore-working-copy", "--no-graph", "--color", "never", "--limit", "1",
+		"-r", "heads(::@ & bookmarks())", "-T", `local_bookmarks.map(|b| b.name()).join(" ") ++ "\n"`)
+	if bookmarks := strings.Fields(output); err == nil && len(bookmarks) > 0 {
+		result.Branch = bookmarks[0]
+		readJujutsuUpstream(root, &result)
+	}
+	if !status {
+		return result
+	}
+
+	output, err = run(root, "jj", "diff", "--ignore-working-copy", "--summary", "--color", "never", "-r", "@")
+	if err == nil {
+		result.UnstagedChanges = len(lines(output))
+	}
+	// jj resolve fails when there are no conflicts
+	output, err = run(root, "jj", "resolve", "--ignore-working-copy", "--list", "--color", "never")
+	if err == nil {
+		result.ConflictedFiles = len(lines(output))
+	}
+	setDirty(&result)
+	return result
+}
+
+// Counts the commits between the bookmark and its remote bookmark on origin
+func readJujutsuUpstream(root string, vcsContext *VCSContext) {
+	local := strconv.Quote(vcsContext.Branch)
+	remote := local + "@origin"
+	count := func(revset string) (int, bool) {
+		output, err := run(root, "jj", "log", "--ignore-working-copy", "--no-graph", "--color", "never", "-r", revset, "-T", `"x\n"`)
+		return len(lines(output)), err == nil
+	}
+	ahead, ok := count(remote + ".." + local)
+	if !ok {
+		return
+	}
+	behind, _ := count(local + ".." + remote)
+	vcsContext.HasUpstream = true
+	vcsContext.Ahead = ahead
+	vcsContext.Behind = behind
+}
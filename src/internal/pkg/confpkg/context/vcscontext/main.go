@@ -0,0 +1,108 @@
+package vcscontext
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"promptorium/internal/log"
+	"strings"
+)
+
+// VCS is the name of a version control system
+type VCS string
+
+const (
+	VCSNone      VCS = ""
+	VCSGit       VCS = "git"
+	VCSMercurial VCS = "hg"
+	VCSJujutsu   VCS = "jj"
+	VCSFossil    VCS = "fossil"
+)
+
+// VCSContext holds the state of the repository containing the current directory, whatever its version control system.
+// Values which are not supported by the version control system are left empty.
+type VCSContext struct {
+	VCS    VCS    `json:"vcs"`
+	IsRepo bool   `json:"is_repo"`
+	Root   string `json:"root"`
+	// Branch, or bookmark for Mercurial and Jujutsu
+	Branch string `json:"branch"`
+	// Short id of the current commit (the change id for Jujutsu)
+	Commit          string `json:"commit"`
+	HasUpstream     bool   `json:"has_upstream"`
+	Ahead           int    `json:"ahead"`
+	Behind          int    `json:"behind"`
+	IsDirty         bool   `json:"is_dirty"`
+	StagedChanges   int    `json:"staged_changes"`
+	UnstagedChanges int    `json:"unstaged_changes"`
+	UntrackedFiles  int    `json:"untracked_files"`
+	ConflictedFiles int    `json:"conflicted_files"`
+	Stashes         int    `json:"stashes"`
+}
+
+// Provider reads the repositories of a version control system
+type Provider interface {
+	Name() VCS
+	// IsRoot reports whether dir is the root of a repository
+	IsRoot(dir string) bool
+	// Read reads the branch and the upstream of the repository at root, and the state of its working tree if status is true
+	Read(root string, status bool) VCSContext
+}
+
+// Providers checked in each directory, in order. Jujutsu comes before git, as its repositories are usually colocated with a git repository.
+var PROVIDERS = []Provider{JujutsuProvider{}, GitProvider{}, MercurialProvider{}, FossilProvider{}}
+
+// FindProvider walks up from dir to the closest repository root, and returns the provider of its version control system
+func FindProvider(dir string) (Provider, string, bool) {
+	for dir != "" {
+		for _, provider := range PROVIDERS {
+			if provider.IsRoot(dir) {
+				return provider, dir, true
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return nil, "", false
+}
+
+// Runs a command in dir and returns its output
+func run(dir string, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	// Disables the user config which changes the output format of Mercurial
+	cmd.Env = append(os.Environ(), "HGPLAIN=1")
+	output, err := cmd.Output()
+	if err != nil {
+		log.Trace().Msgf("Error running %s %s: %s", name, strings.Join(args, " "), err)
+	}
+	return string(output), err
+}
+
+// Returns the non-empty lines of the output of a command
+func lines(output string) []string {
+	result := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) != "" {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+func setDirty(vcsContext *VCSContext) {
+	vcsContext.IsDirty = vcsContext.StagedChanges > 0 || vcsContext.UnstagedChanges > 0 || vcsContext.UntrackedFiles > 0 || vcsContext.ConflictedFiles > 0
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,78 @@
+package vcscontext
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MercurialProvider reads Mercurial repositories. The branch, the active bookmark and the parent commit are read
+// from the .hg directory, and hg is only run for the status. Ahead and behind are not supported, as they require
+// contacting the remote repository.
+type MercurialProvider struct{}
+
+func (MercurialProvider) Name() VCS {
+	return VCSMercurial
+}
+
+func (MercurialProvider) IsRoot(dir string) bool {
+	return isDir(filepath.Join(dir, ".hg"))
+}
+
+func (MercurialProvider) Read(root string, status bool) VCSContext {
+	hgDir := filepath.Join(root, ".hg")
+	result := VCSContext{VCS: VCSMercurial, IsRepo: true, Root: root, Branch: "default"}
+	if branch, err := os.ReadFile(filepath.Join(hgDir, "branch")); err == nil && strings.TrimSpace(string(branch)) != "" {
+		result.Branch = strings.TrimSpace(string(branch))
+	}
+	if bookmark, err := os.ReadFile(filepath.Join(hgDir, "bookmarks.current")); err == nil && strings.TrimSpace(string(bookmark)) != "" {
+		result.Branch = strings.TrimSpace(string(bookmark))
+	}
+	result.Commit = readMercurialParent(hgDir)
+	if !status {
+		return result
+	}
+
+	output, err := run(root, "hg", "status", "--color", "never")
+	if err != nil {
+		return result
+	}
+	for _, line := range lines(output) {
+		switch line[0] {
+		case 'A', 'R':
+			result.StagedChanges++
+		case 'M', '!':
+			result.UnstagedChanges++
+		case '?':
+			result.UntrackedFiles++
+		}
+	}
+	// The merge state only exists during a merge, which avoids running hg resolve most of the time
+	if isDir(filepath.Join(hgDir, "merge")) {
+		output, err := run(root, "hg", "resolve", "--list", "--color", "never")
+		if err == nil {
+			for _, line := range lines(output) {
+				if strings.HasPrefix(line, "U ") {
+					result.ConflictedFiles++
+				}
+			}
+		}
+	}
+	setDirty(&result)
+	return result
+}
+
+// Returns the short id of the first parent of the working directory, which is at the start of the dirstate
+func readMercurialParent(hgDir string) string {
+	dirstate, err := os.ReadFile(filepath.Join(hgDir, "dirstate"))
+	if err != nil {
+		return ""
+	}
+	dirstate, _ = bytes.CutPrefix(dirstate, []byte("dirstate-v2\n"))
+	if len(dirstate) < 6 {
+		return ""
+	}
+	return hex.EncodeToString(dirstate[:6])
+}
@@ -0,0 +1,27 @@
+package contextpkg
+
+import (
+	"encoding/json"
+	"os"
+	"promptorium/internal/log"
+	"promptorium/internal/pkg/confpkg/context"
+)
+
+// DumpContext resolves the application context and returns it as JSON.
+// If outputPath is not empty, the JSON is written to that file instead.
+func DumpContext(values context.ShellValues, outputPath string) (string, error) {
+	snapshot := context.GetApplicationContext(values).Snapshot()
+
+	output, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	output = append(output, '\n')
+
+	if outputPath == "" {
+		return string(output), nil
+	}
+
+	log.Trace().Msgf("Writing context to %s", outputPath)
+	return "", os.WriteFile(outputPath, output, 0644)
+}
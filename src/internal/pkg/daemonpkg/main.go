@@ -0,0 +1,112 @@
+package daemonpkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"promptorium/internal/daemon"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+var START_TIMEOUT = 2 * time.Second
+
+// StartDaemon starts the daemon in the background and waits until it accepts requests
+func StartDaemon() (string, error) {
+	if status, err := daemon.GetStatus(); err == nil {
+		return "", fmt.Errorf("the daemon is already running (pid %d)", status.PID)
+	}
+	err := daemon.PrepareSocketDir(daemon.GetSocketPath())
+	if err != nil {
+		return "", err
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.Command(executable, "daemon", "start", "--foreground")
+	// Detach the daemon from the terminal and the session of the shell
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	err = cmd.Start()
+	if err != nil {
+		return "", err
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	deadline := time.Now().Add(START_TIMEOUT)
+	for time.Now().Before(deadline) {
+		select {
+		case err := <-exited:
+			return "", fmt.Errorf("the daemon exited: %v", err)
+		case <-time.After(20 * time.Millisecond):
+		}
+		if status, err := daemon.GetStatus(); err == nil {
+			return fmt.Sprintf("Daemon started (pid %d)\n", status.PID), nil
+		}
+	}
+	return "", errors.New("the daemon did not start in time")
+}
+
+// RunDaemon runs the daemon in the foreground until it is stopped
+func RunDaemon(version string) error {
+	return daemon.Run(version)
+}
+
+// StopDaemon stops the daemon and waits until it exits
+func StopDaemon() (string, error) {
+	status, err := daemon.GetStatus()
+	if err != nil {
+		return "", errors.New("the daemon is not running")
+	}
+	err = daemon.Stop()
+	if err != nil {
+		return "", err
+	}
+
+	deadline := time.Now().Add(START_TIMEOUT)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(daemon.GetSocketPath()); os.IsNotExist(err) {
+			return fmt.Sprintf("Daemon stopped (pid %d)\n", status.PID), nil
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return "", errors.New("the daemon did not stop in time")
+}
+
+// GetDaemonStatus returns the state of the daemon and of the repositories it watches
+func GetDaemonStatus() (string, error) {
+	status, err := daemon.GetStatus()
+	if err != nil {
+		return "", errors.New("the daemon is not running")
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Status:  running\n")
+	fmt.Fprintf(&builder, "PID:     %d\n", status.PID)
+	fmt.Fprintf(&builder, "Version: %s\n", status.Version)
+	fmt.Fprintf(&builder, "Uptime:  %s\n", time.Since(status.StartedAt).Round(time.Second))
+	fmt.Fprintf(&builder, "Socket:  %s\n", daemon.GetSocketPath())
+	if len(status.Repositories) == 0 {
+		fmt.Fprintln(&builder, "No watched repositories")
+		return builder.String(), nil
+	}
+
+	fmt.Fprintln(&builder)
+	writer := tabwriter.NewWriter(&builder, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "REPOSITORY\tWATCHES\tCACHED\tLAST USED")
+	for _, repo := range status.Repositories {
+		watches := fmt.Sprint(repo.Watches)
+		if !repo.Watched {
+			watches = "none"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%t\t%s ago\n", repo.Root, watches, repo.Cached, time.Since(repo.LastUsed).Round(time.Second))
+	}
+	writer.Flush()
+
+	return builder.String(), nil
+}
@@ -4,9 +4,8 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"promptorium/internal/log"
 	"strings"
-
-	"github.com/rs/zerolog/log"
 )
 
 func InitPromptorium() {
@@ -95,7 +94,7 @@ func copyPresetFiles() {
 }
 
 func addSourceLines() {
-	// Add line to ~/.bashrc and/or ~/.zshrc to source promptorium shell
+	// Add line to ~/.bashrc, ~/.zshrc and/or the pwsh $PROFILE to source promptorium shell, and a conf.d file for fish
 	_, err := os.Stat("/home/" + os.Getenv("USER") + "/.zshrc")
 	if err == nil {
 		log.Trace().Msg("Found .zshrc file")
@@ -107,12 +106,52 @@ func addSourceLines() {
 		log.Trace().Msg("Found .bashrc file")
 		addSourceLine("/home/"+os.Getenv("USER")+"/.bashrc", "bash")
 	}
+
+	_, err = os.Stat("/home/" + os.Getenv("USER") + "/.config/fish")
+	if err == nil {
+		log.Trace().Msg("Found fish config directory")
+		addFishConfFile("/home/" + os.Getenv("USER") + "/.config/fish/conf.d")
+	}
+
+	// The $PROFILE of pwsh on Linux, which doesn't exist until the user customizes pwsh
+	_, err = exec.LookPath("pwsh")
+	if err == nil {
+		log.Trace().Msg("Found pwsh")
+		profileDir := "/home/" + os.Getenv("USER") + "/.config/powershell"
+		if err = os.MkdirAll(profileDir, 0755); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		addSourceLine(profileDir+"/Microsoft.PowerShell_profile.ps1", "pwsh")
+	}
+}
+
+func addFishConfFile(confDir string) {
+	// Fish sources the files of conf.d at startup, so promptorium gets its own file instead of a line in config.fish
+	var fileContents = "if type -q promptorium\n    promptorium shell --shell fish | source\nend\n"
+	err := os.MkdirAll(confDir, 0755)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	file := confDir + "/promptorium.fish"
+	if _, err = os.Stat(file); err == nil {
+		log.Trace().Msg("File already exists")
+		return
+	}
+	log.Trace().Msgf("Creating %s", file)
+	if err = os.WriteFile(file, []byte(fileContents), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
 }
 
 func addSourceLine(file string, shell string) {
-	// Add line to .bashrc or .zshrc to source promptorium shell
+	// Add line to .bashrc, .zshrc or the pwsh profile to source promptorium shell
 	var lineToAdd = "if [[ -n $(command -v promptorium) ]]; then source <(promptorium shell --shell " + shell + "); fi"
-	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0644)
+	if shell == "pwsh" {
+		lineToAdd = "if (Get-Command promptorium -ErrorAction SilentlyContinue) { promptorium shell --shell pwsh | Out-String | Invoke-Expression }"
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 	}
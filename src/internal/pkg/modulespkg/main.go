@@ -0,0 +1,58 @@
+package modulespkg
+
+import (
+	"fmt"
+	"promptorium/internal/pkg/confpkg/config"
+	"strings"
+	"text/tabwriter"
+)
+
+// ListModules returns a table with the name and description of every available module
+func ListModules() string {
+	var builder strings.Builder
+	writer := tabwriter.NewWriter(&builder, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(writer, "NAME\tDESCRIPTION")
+	for _, module := range config.GetModuleRegistry().List() {
+		fmt.Fprintf(writer, "%s\t%s\n", module.Name, module.Description)
+	}
+	writer.Flush()
+
+	return builder.String()
+}
+
+// DescribeModule returns the description, options, context providers and example output of a module
+func DescribeModule(name string) (string, error) {
+	module, ok := config.GetModuleRegistry().Get(name)
+	if !ok {
+		return "", fmt.Errorf("module %s not found", name)
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Name:        %s\n", module.Name)
+	fmt.Fprintf(&builder, "Description: %s\n", module.Description)
+	fmt.Fprintf(&builder, "Example:     %s\n", module.Example)
+
+	providers := []string{}
+	for _, provider := range module.Providers {
+		providers = append(providers, string(provider))
+	}
+	if len(providers) == 0 {
+		providers = append(providers, "none")
+	}
+	fmt.Fprintf(&builder, "Providers:   %s\n", strings.Join(providers, ", "))
+
+	if len(module.Options) == 0 {
+		fmt.Fprintln(&builder, "Options:     none")
+		return builder.String(), nil
+	}
+
+	fmt.Fprintln(&builder, "Options:")
+	writer := tabwriter.NewWriter(&builder, 0, 4, 2, ' ', 0)
+	for _, option := range module.Options {
+		fmt.Fprintf(writer, "  %s\t%s\t(default: %s)\t%s\n", option.Name, option.Type, option.Default, option.Description)
+	}
+	writer.Flush()
+
+	return builder.String(), nil
+}
@@ -1,11 +1,12 @@
 package promptpkg
 
 import (
+	"promptorium/internal/log"
 	"promptorium/internal/pkg/confpkg/config"
+	"promptorium/internal/pkg/confpkg/context"
+	"strconv"
 	"strings"
 	"unicode/utf8"
-
-	"github.com/rs/zerolog/log"
 )
 
 var SPACER_PROMPT_ELEMENT = "---"
@@ -45,18 +46,95 @@ func (p Prompt) Render() string {
 }
 
 func (p PromptLine) Render() string {
+	leftPart, leftPartLen, rightPart, rightPartLen, foundSpacer := p.renderParts()
+
+	result := leftPart
+	if foundSpacer {
+		result += p.getSpacer(leftPartLen, rightPartLen)
+	}
+	result += rightPart
+	return result
+}
+
+// Renders both sides of the prompt in a single invocation, separated by PART_SEPARATOR for the shell scripts to split on
+var PART_BOTH = config.Align("both")
+
+var PART_SEPARATOR = "\x1f"
+
+// ParsePart returns the part of the prompt to render: left, right or both
+func ParsePart(part string) (config.Align, bool) {
+	if part == string(PART_BOTH) {
+		return PART_BOTH, true
+	}
+	align, ok := config.Alignments[part]
+	return align, ok
+}
+
+// RenderPart renders one side of the prompt, for the shells which draw the right prompt themselves.
+// The right part is the right side of the last line, the left part is everything else.
+func (p Prompt) RenderPart(part config.Align) string {
+	if len(p.PromptLines) == 0 {
+		if part == PART_BOTH {
+			return PART_SEPARATOR
+		}
+		return ""
+	}
+	lastLine := p.PromptLines[len(p.PromptLines)-1]
+	leftPart, _, rightPart, rightPartLen, _ := lastLine.renderParts()
+	if p.Config.Context.Shell.GetContent() == context.ShellBash {
+		rightPart = getBashRightPrompt(rightPart, rightPartLen)
+	}
+	if part == config.Alignments["right"] {
+		return rightPart
+	}
+
 	result := ""
+	for _, line := range p.PromptLines[:len(p.PromptLines)-1] {
+		result += line.Render() + "\n"
+	}
+	result += leftPart
+	if part == PART_BOTH {
+		result += PART_SEPARATOR + rightPart
+	}
+	return result
+}
 
-	leftPartComponents := []PromptComponent{}
-	rightPartComponents := []PromptComponent{}
+// Bash has no right prompt, so the right part is appended to PS1: the cursor is saved, moved to the right edge of the
+// terminal and restored once the right part is printed. The whole part is zero width for bash, so the color escapes of
+// the components are unwrapped. Like in zsh, the last column is left empty.
+func getBashRightPrompt(rightPart string, rightPartLen int) string {
+	if rightPart == "" {
+		return ""
+	}
+	rightPart = strings.NewReplacer("\\[", "", "\\]", "").Replace(rightPart)
+	return "\\[\x1b7\x1b[999C\x1b[" + strconv.Itoa(rightPartLen) + "D" + rightPart + "\x1b8\\]"
+}
+
+// Renders the components before and after the spacer, and returns their lengths
+func (p PromptLine) renderParts() (string, int, string, int, bool) {
+	leftPartComponents, rightPartComponents, foundSpacer := p.splitAtSpacer()
 
 	leftPart := ""
 	leftPartLen := 0
 	rightPart := ""
 	rightPartLen := 0
 
-	spacer := ""
-	spacerLen := 0
+	for _, component := range leftPartComponents {
+		leftPart += component.Render()
+		leftPartLen += component.Len
+	}
+
+	for _, component := range rightPartComponents {
+		rightPart += component.Render()
+		rightPartLen += component.Len
+	}
+	return leftPart, leftPartLen, rightPart, rightPartLen, foundSpacer
+}
+
+// Splits the components of the line into the ones before and after the spacer
+func (p PromptLine) splitAtSpacer() ([]PromptComponent, []PromptComponent, bool) {
+	leftPartComponents := []PromptComponent{}
+	rightPartComponents := []PromptComponent{}
 
 	// Check if the line has a spacer component
 	foundSpacer := false
@@ -67,40 +145,24 @@ func (p PromptLine) Render() string {
 		}
 		if foundSpacer {
 			rightPartComponents = append(rightPartComponents, component)
-			rightPartLen += component.Len
 		} else {
 			leftPartComponents = append(leftPartComponents, component)
-			leftPartLen += component.Len
 		}
 	}
+	return leftPartComponents, rightPartComponents, foundSpacer
+}
 
-	for _, component := range leftPartComponents {
-		leftPart += component.Render()
-	}
-
-	for _, component := range rightPartComponents {
-		rightPart += component.Render()
-	}
-
-	// Calculate spacer length
-	if foundSpacer {
-		spacerLen = p.Config.Context.TerminalWidth.GetContent() - leftPartLen - rightPartLen
-		spacerChar := p.Config.Theme.Spacer
-		if spacerChar == "" {
-			spacerChar = " "
-		}
-		if spacerLen > 0 {
-			spacer = strings.Repeat(spacerChar, spacerLen)
-
-		}
+// Returns the string filling the space between the left and right parts of the line
+func (p PromptLine) getSpacer(leftPartLen int, rightPartLen int) string {
+	spacerLen := p.Config.Context.TerminalWidth.GetContent() - leftPartLen - rightPartLen
+	spacerChar := p.Config.Theme.Spacer
+	if spacerChar == "" {
+		spacerChar = " "
 	}
-
-	result = leftPart
-	if foundSpacer {
-		result += spacer
+	if spacerLen > 0 {
+		return strings.Repeat(spacerChar, spacerLen)
 	}
-	result += rightPart
-	return result
+	return ""
 }
 
 func (p PromptComponent) Render() string {
@@ -196,7 +258,17 @@ func (b PromptComponentBuilder) BuildPromptComponent() PromptComponent {
 			log.Error().Msgf("Module %s not found", b.Component.Content)
 			return PromptComponent{}
 		}
-		componentContent = addDecorationsContent(module.Get(&b.Config, &b.Component), b.Component, b.Component.Style, b.Config)
+		moduleContent := module.Get(&b.Config, &b.Component)
+		if b.Config.Context.TimedOut(module.Providers...) {
+			moduleContent = append(moduleContent, config.GetTimeoutMarkerContent(&b.Config, &b.Component)...)
+		}
+		componentContent = addDecorationsContent(moduleContent, b.Component, b.Component.Style, b.Config)
+
+	case "plugin":
+		componentContent = addDecorationsContent(config.GetPluginContent(&b.Config, &b.Component), b.Component, b.Component.Style, b.Config)
+
+	case "command":
+		componentContent = addDecorationsContent(config.GetCommandContent(&b.Config, &b.Component), b.Component, b.Component.Style, b.Config)
 
 	case "text":
 		componentContent = addDecorationsContent([]config.ComponentContent{config.NewComponentContent(&b.Component, b.Component.Content, utf8.RuneCountInString(b.Component.Content))}, b.Component, b.Component.Style, b.Config)
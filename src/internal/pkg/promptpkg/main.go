@@ -1,11 +1,43 @@
 package promptpkg
 
 import (
+	"fmt"
+	"os"
 	"promptorium/internal/pkg/confpkg/config"
+	"promptorium/internal/pkg/confpkg/context"
 )
 
-func GetPrompt(configPath string, shell string, exitCode int, version string) string {
-	config := config.GetConfig(configPath, shell, exitCode, version)
-	return NewPromptBuilder(config).BuildPrompt().Render()
+// GetPrompt renders the whole prompt, or only one side of it if part is set.
+// The transient prompt is rendered instead if transient is set.
+func GetPrompt(configPath string, values context.ShellValues, contextFile string, part config.Align, transient bool, version string) string {
+	if contextFile != "" {
+		return getPromptFromContextFile(configPath, contextFile, part, transient, version)
+	}
+	config := getConfig(configPath, context.GetApplicationContext(values), transient, version)
+	return renderPrompt(NewPromptBuilder(config).BuildPrompt(), part)
+}
+
+func getConfig(configPath string, appContext *context.ApplicationContext, transient bool, version string) config.Config {
+	if transient {
+		return config.GetTransientConfigWithContext(configPath, appContext, version)
+	}
+	return config.GetConfigWithContext(configPath, appContext, version)
+}
+
+func renderPrompt(prompt Prompt, part config.Align) string {
+	if part != "" {
+		return prompt.RenderPart(part)
+	}
+	return prompt.Render()
+}
 
+// Renders the prompt from a context snapshot instead of probing the system
+func getPromptFromContextFile(configPath string, contextFile string, part config.Align, transient bool, version string) string {
+	snapshot, err := context.ReadSnapshot(contextFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "promptorium: Could not read context file", contextFile, ":", err)
+		return ""
+	}
+	config := getConfig(configPath, context.NewApplicationContextFromSnapshot(snapshot), transient, version)
+	return renderPrompt(NewPromptBuilder(config).BuildPrompt(), part)
 }
@@ -0,0 +1,81 @@
+package promptpkg
+
+import (
+	"promptorium/internal/utils"
+	"strings"
+)
+
+// Segment is a piece of rendered prompt text together with its style, without any escape codes
+type Segment struct {
+	Line            int
+	Component       string
+	Text            string
+	Len             int
+	ForegroundColor string
+	BackgroundColor string
+	Bold            bool
+	Underline       bool
+	IsSpacer        bool
+}
+
+// Segments returns the prompt as a list of styled segments, in display order
+func (p Prompt) Segments() []Segment {
+	result := []Segment{}
+	for i, line := range p.PromptLines {
+		result = append(result, line.Segments(i)...)
+	}
+	return result
+}
+
+func (p PromptLine) Segments(lineNumber int) []Segment {
+	result := []Segment{}
+
+	leftPartComponents, rightPartComponents, foundSpacer := p.splitAtSpacer()
+
+	leftPartLen := 0
+	for _, component := range leftPartComponents {
+		result = append(result, component.Segments(lineNumber)...)
+		leftPartLen += component.Len
+	}
+
+	rightPart := []Segment{}
+	rightPartLen := 0
+	for _, component := range rightPartComponents {
+		rightPart = append(rightPart, component.Segments(lineNumber)...)
+		rightPartLen += component.Len
+	}
+
+	if foundSpacer {
+		spacer := p.getSpacer(leftPartLen, rightPartLen)
+		result = append(result, Segment{
+			Line:            lineNumber,
+			Component:       SPACER_PROMPT_ELEMENT,
+			Text:            spacer,
+			Len:             len([]rune(spacer)),
+			ForegroundColor: p.Config.Theme.ForegroundColor.Name,
+			BackgroundColor: p.Config.Theme.BackgroundColor.Name,
+			IsSpacer:        true,
+		})
+	}
+
+	return append(result, rightPart...)
+}
+
+func (p PromptComponent) Segments(lineNumber int) []Segment {
+	result := []Segment{}
+	for _, content := range p.Content {
+		// Decorations (icons, dividers, margins) are stored already colorized
+		text := utils.StripANSI(strings.NewReplacer("\\[", "", "\\]", "", "%{", "", "%}", "").Replace(content.Str))
+		result = append(result, Segment{
+			Line:            lineNumber,
+			Component:       strings.TrimPrefix(p.Component.Name, "$"),
+			Text:            text,
+			Len:             content.Len,
+			ForegroundColor: content.ForegroundColor.Name,
+			BackgroundColor: content.BackgroundColor.Name,
+			Bold:            content.Bold,
+			Underline:       content.Underline,
+		})
+	}
+	return result
+}
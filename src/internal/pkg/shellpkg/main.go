@@ -4,8 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-
-	"github.com/rs/zerolog/log"
+	"promptorium/internal/log"
+	"promptorium/internal/pkg/confpkg/config"
+	"strings"
 )
 
 func GetShellScript(shell string, configPath string) string {
@@ -31,40 +32,187 @@ func getShellScript(configPath string, shell string) string {
 
 	switch shell {
 	case "bash":
-		return getBashScript(configPath)
+		return getBashScript(configPath, config.HasTransientPrompt(configPath))
 	case "zsh":
-		return getZshScript(configPath)
+		return getZshScript(configPath, config.HasTransientPrompt(configPath))
+	case "fish":
+		return getFishScript(configPath)
+	case "pwsh":
+		return getPwshScript(configPath)
+	case "nu":
+		return getNuScript(configPath)
 	default:
 		return ""
 	}
 }
 
-func getBashScript(configPath string) string {
+// The bash script records the start time of the commands with the preexec hook of bash-preexec if it is loaded,
+// or with a DEBUG trap otherwise. The right part of the prompt is drawn at the end of PS1, as bash has no right prompt.
+// If the config has a transient prompt, it is drawn by the same hook, before the first command runs. The command is the
+// one passed by bash-preexec, or $BASH_COMMAND, as the history doesn't contain the commands ignored by HISTCONTROL.
+func getBashScript(configPath string, transient bool) string {
 	bashScript := `
 	#!/bin/bash
-		function prompt_cmd() {
-		local exit_code="$?"
-		local promptorium_output config_file
+	function _promptorium_preexec() {
+		# Only the first command run from the prompt is timed
+		[ -n "$_promptorium_at_prompt" ] || return
+		[ "$BASH_COMMAND" = "$PROMPT_COMMAND" ] && return
+		_promptorium_at_prompt=
+		_promptorium_start_time=${EPOCHREALTIME:-$(date +%s)}`
+	if transient {
+		bashScript += `
+		_promptorium_transient "${1:-$BASH_COMMAND}"`
+	}
+	bashScript += `
+	}
+	function prompt_cmd() {
+		local exit_code="$?" pipe_status="${PIPESTATUS[*]}"
+		local config_file start_time="$_promptorium_start_time" jobs_count promptorium_output
+		_promptorium_start_time=
+		jobs_count=$(jobs -p | wc -l)
 		config_file=` + configPath + `
-		promptorium_output=$(promptorium prompt --shell bash --config-file "$config_file" --exit-code "$exit_code")
-		PS1="$promptorium_output"
+		_promptorium_args=(--shell bash --config-file "$config_file" --exit-code "$exit_code" --pipestatus "$pipe_status" --start-time "$start_time" --jobs "$((jobs_count))")
+		promptorium_output=$(promptorium prompt "${_promptorium_args[@]}" --part both)
+		PS1="${promptorium_output/$'\x1f'/}"
+		_promptorium_at_prompt=1
+	}
+	if [ -n "${bash_preexec_imported:-}" ]; then
+		preexec_functions+=(_promptorium_preexec)
+	else
+		trap '_promptorium_preexec' DEBUG
+	fi
+	PROMPT_COMMAND=prompt_cmd`
+	if transient {
+		bashScript += `
+	function _promptorium_transient() {
+		# Moves the cursor back to the first line of the prompt, and redraws the accepted command after the transient prompt
+		local transient command="$1" visible_prompt line rows=0
+		transient=$(promptorium prompt "${_promptorium_args[@]}" --transient --part both)
+		transient=${transient/$'\x1f'/}
+		transient=${transient//\\[/}
+		transient=${transient//\\]/}
+		# Counts the rows taken by the prompt and the command, including the lines wrapped by the terminal
+		visible_prompt=$PS1
+		while [[ $visible_prompt == *'\['*'\]'* ]]; do
+			visible_prompt=${visible_prompt%%'\['*}${visible_prompt#*'\]'}
+		done
+		while IFS= read -r line; do
+			rows=$((rows + (${#line} > 0 ? (${#line} - 1) / ${COLUMNS:-80} + 1 : 1)))
+		done <<< "$visible_prompt$command"
+		printf '\e[%dA\r\e[J%s%s\e[1E' "$rows" "$transient" "$command"
+	}`
 	}
-	PROMPT_COMMAND=prompt_cmd
+	bashScript += `
 	source /etc/bash_completion
 	source <(promptorium completion bash)`
 	return bashScript
 }
 
-func getZshScript(configPath string) string {
+// If the config has a transient prompt, it is drawn by a zle-line-finish hook
+func getZshScript(configPath string, transient bool) string {
 	zshScript := `
+	zmodload zsh/datetime
+	function _promptorium_preexec() {
+		_promptorium_start_time=$EPOCHREALTIME
+	}
 	function set_prompt() {
-		local exit_code="$?"
-		local promptorium_output config_file
+		local exit_code="$?" pipe_status="${pipestatus[*]}"
+		local config_file start_time="$_promptorium_start_time" promptorium_output
+		_promptorium_start_time=
 		config_file=` + configPath + `
-		promptorium_output=$(promptorium prompt --shell zsh --config-file "$config_file" --exit-code "$exit_code")
-		PROMPT="$promptorium_output"
+		_promptorium_args=(--shell zsh --config-file "$config_file" --exit-code "$exit_code" --pipestatus "$pipe_status" --start-time "$start_time" --jobs "${#jobstates}")
+		promptorium_output="$(promptorium prompt "${_promptorium_args[@]}" --part both)"
+		PROMPT="${promptorium_output%%$'\x1f'*}"
+		RPROMPT="${promptorium_output#*$'\x1f'}"
+	}
+	preexec_functions+=_promptorium_preexec
+	precmd_functions+=set_prompt`
+	if transient {
+		zshScript += `
+	function _promptorium_line_finish() {
+		local promptorium_output
+		promptorium_output="$(promptorium prompt "${_promptorium_args[@]}" --transient --part both)"
+		PROMPT="${promptorium_output%%$'\x1f'*}"
+		RPROMPT="${promptorium_output#*$'\x1f'}"
+		zle reset-prompt
 	}
-	precmd_functions+=set_prompt
+	autoload -Uz add-zle-hook-widget
+	add-zle-hook-widget zle-line-finish _promptorium_line_finish`
+	}
+	zshScript += `
 	source <(promptorium completion zsh)`
 	return zshScript
 }
+
+// Fish measures the duration of the commands itself, in $CMD_DURATION. The duration is only passed when a command ran,
+// as fish keeps the duration of the last command when the prompt is redrawn. The status and the pipestatus are captured
+// by the same set, since every command resets them. The arguments are kept for fish_right_prompt, which fish calls
+// after fish_prompt, to render the right side of the last line.
+func getFishScript(configPath string) string {
+	fishScript := `
+	set -g _promptorium_cmd_duration 0
+	function _promptorium_postexec --on-event fish_postexec
+		set -g _promptorium_cmd_duration $CMD_DURATION
+	end
+	function fish_prompt
+		set -l last_status $status $pipestatus
+		set -l cmd_duration $_promptorium_cmd_duration
+		set -g _promptorium_cmd_duration 0
+		set -l config_file "` + configPath + `"
+		set -g _promptorium_args --shell fish --config-file "$config_file" --exit-code $last_status[1] --pipestatus "$last_status[2..-1]" --cmd-duration "$cmd_duration" --jobs (count (jobs -p))
+		promptorium prompt $_promptorium_args --part left
+	end
+	function fish_right_prompt
+		promptorium prompt $_promptorium_args --part right
+	end
+	promptorium completion fish | source`
+	return fishScript
+}
+
+// $? only tells whether the last command succeeded, the exit code of native commands is in $LASTEXITCODE.
+// The duration is read from the history, once per command, and empty arguments are not passed since
+// pwsh drops them before 7.3.
+// The config path is written in a single-quoted string, in which quotes are escaped by doubling them
+func getPwshScript(configPath string) string {
+	pwshScript := `
+	function global:prompt {
+		$lastSucceeded = $global:?
+		$lastExitCode = $global:LASTEXITCODE
+		$exitCode = if ($lastSucceeded) { 0 } elseif ($lastExitCode) { $lastExitCode } else { 1 }
+		$arguments = @('prompt', '--shell', 'pwsh', "--exit-code=$exitCode")
+		$configFile = '` + strings.ReplaceAll(configPath, "'", "''") + `'
+		if ($configFile) { $arguments += "--config-file=$configFile" }
+		$lastCommand = Get-History -Count 1
+		if ($lastCommand -and $lastCommand.Id -ne $global:_promptorium_history_id) {
+			$global:_promptorium_history_id = $lastCommand.Id
+			$duration = [int64]($lastCommand.EndExecutionTime - $lastCommand.StartExecutionTime).TotalMilliseconds
+			$arguments += "--cmd-duration=$duration"
+		}
+		$jobs = @(Get-Job | Where-Object { $_.State -in 'Running', 'Suspended' }).Count
+		$arguments += "--jobs=$jobs"
+		$output = & promptorium @arguments
+		$global:LASTEXITCODE = $lastExitCode
+		$output -join [Environment]::NewLine
+	}
+	promptorium completion powershell | Out-String | Invoke-Expression`
+	return pwshScript
+}
+
+// Nushell can't source the output of a command, so the script is meant to be saved in env.nu or in an autoload directory.
+// Both parts are rendered once by a pre_prompt hook, as the hooks can set environment variables and the prompt closures
+// can't. The closures only read them, and the right prompt is drawn on the last line like the spacer.
+func getNuScript(configPath string) string {
+	nuScript := `
+	$env.PROMPT_INDICATOR = ""
+	$env.PROMPT_INDICATOR_VI_INSERT = ""
+	$env.PROMPT_INDICATOR_VI_NORMAL = ""
+	$env.PROMPT_COMMAND = {|| $env._PROMPTORIUM_PROMPT? | default [""] | first }
+	$env.PROMPT_COMMAND_RIGHT = {|| $env._PROMPTORIUM_PROMPT? | default ["" ""] | last }
+	$env.config = ($env.config? | default {} | merge {render_right_prompt_on_last_line: true})
+	$env.config = ($env.config | upsert hooks ($env.config.hooks? | default {} | upsert pre_prompt (
+		$env.config.hooks?.pre_prompt? | default [] | append {||
+			$env._PROMPTORIUM_PROMPT = (^promptorium prompt --shell nu --config-file "` + configPath + `" --part both --exit-code $env.LAST_EXIT_CODE --cmd-duration $env.CMD_DURATION_MS | split row "\u{1f}")
+		}
+	)))`
+	return nuScript
+}
@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"errors"
+	"promptorium/internal/log"
+	"sync"
+	"time"
+)
+
+/*
+ * ---------------- Cached Data ----------------
+ * CachedData fetches a value in the background once started, and caches it on the first read.
+ * Reading a CachedData which wasn't started starts it.
+ * Reads can be bounded by a timeout and a deadline: when the value isn't ready in time, the fallback
+ * (if any) is used instead, and the data is marked as timed out.
+ * The result of the first read is kept, so every reader sees the same value.
+ * Copies of a CachedData share the same state, and it is safe for concurrent use.
+ */
+
+var ErrTimeout = errors.New("timed out")
+
+type CachedData[T any] struct {
+	state *cachedDataState[T]
+}
+
+type cachedDataState[T any] struct {
+	mutex     sync.Mutex
+	name      string
+	done      chan struct{}
+	refresh   func() (T, error)
+	startOnce sync.Once
+
+	// Set by the background fetch
+	fetchedContent T
+	fetchedErr     error
+
+	// Set by the first read
+	isCached bool
+	content  T
+	err      error
+	timedOut bool
+
+	started  time.Time
+	timeout  time.Duration
+	deadline time.Time
+	fallback func() (T, bool)
+}
+
+// NewCachedData returns a CachedData which fetches the value by calling refresh, which must send the value on the channel
+func NewCachedData[T any](refresh func(chan T), name string) CachedData[T] {
+	return NewCachedDataWithError(func() (T, error) {
+		result := make(chan T, 1)
+		go refresh(result)
+		return <-result, nil
+	}, name)
+}
+
+// NewCachedDataWithError returns a CachedData which fetches the value by calling refresh, which can fail with an error
+func NewCachedDataWithError[T any](refresh func() (T, error), name string) CachedData[T] {
+	state := &cachedDataState[T]{
+		name:    name,
+		done:    make(chan struct{}),
+		refresh: refresh,
+	}
+
+	return CachedData[T]{state: state}
+}
+
+// Start fetches the value in the background. Calling it more than once has no effect
+func (c *CachedData[T]) Start() {
+	state := c.state
+	if state == nil {
+		return
+	}
+	state.startOnce.Do(func() {
+		log.Trace().Msgf("Starting %s", state.name)
+		state.mutex.Lock()
+		state.started = time.Now()
+		state.mutex.Unlock()
+
+		go func() {
+			content, err := state.refresh()
+			state.mutex.Lock()
+			state.fetchedContent = content
+			state.fetchedErr = err
+			state.mutex.Unlock()
+			close(state.done)
+		}()
+	})
+}
+
+// GetContent returns the value, or the fallback value if it couldn't be fetched in time.
+// Errors are logged and result in the zero value (or the fallback value, if any).
+func (c *CachedData[T]) GetContent() T {
+	content, err := c.Get()
+	if err != nil && c.state != nil {
+		log.Debug().Msgf("Could not get %s: %s", c.state.name, err)
+	}
+	return content
+}
+
+// Get returns the value and the error returned while fetching it, or ErrTimeout if it wasn't fetched in time
+func (c *CachedData[T]) Get() (T, error) {
+	state := c.state
+	if state == nil {
+		var zero T
+		return zero, nil
+	}
+	c.Start()
+
+	state.mutex.Lock()
+	if state.isCached {
+		defer state.mutex.Unlock()
+		return state.content, state.err
+	}
+	wait, isLimited := state.getWaitDuration()
+	state.mutex.Unlock()
+
+	var timeout <-chan time.Time
+	if isLimited {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	fetched := false
+	select {
+	case <-state.done:
+		fetched = true
+	default:
+		select {
+		case <-state.done:
+			fetched = true
+		case <-timeout:
+		}
+	}
+
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	if state.isCached {
+		// Another reader got there first
+		return state.content, state.err
+	}
+
+	state.isCached = true
+	if fetched {
+		state.content, state.err = state.fetchedContent, state.fetchedErr
+		if state.err != nil && state.fallback != nil {
+			if fallback, ok := state.fallback(); ok {
+				state.content = fallback
+			}
+		}
+		return state.content, state.err
+	}
+
+	log.Debug().Msgf("Timed out waiting for %s", state.name)
+	state.timedOut = true
+	state.err = ErrTimeout
+	if state.fallback != nil {
+		if fallback, ok := state.fallback(); ok {
+			state.content = fallback
+		}
+	}
+	return state.content, state.err
+}
+
+// Error returns the error of the value, reading it if necessary
+func (c *CachedData[T]) Error() error {
+	_, err := c.Get()
+	return err
+}
+
+// TimedOut reports whether the value was not fetched in time, reading it if necessary
+func (c *CachedData[T]) TimedOut() bool {
+	if c.state == nil {
+		return false
+	}
+	c.Get()
+	c.state.mutex.Lock()
+	defer c.state.mutex.Unlock()
+	return c.state.timedOut
+}
+
+// SetTimeout limits the time to wait for the value, counted from the start of the fetch
+func (c *CachedData[T]) SetTimeout(timeout time.Duration) {
+	if c.state == nil {
+		return
+	}
+	c.state.mutex.Lock()
+	defer c.state.mutex.Unlock()
+	c.state.timeout = timeout
+}
+
+// SetDeadline sets a point in time after which reads stop waiting for the value
+func (c *CachedData[T]) SetDeadline(deadline time.Time) {
+	if c.state == nil {
+		return
+	}
+	c.state.mutex.Lock()
+	defer c.state.mutex.Unlock()
+	c.state.deadline = deadline
+}
+
+// SetFallback sets the function providing the value used when the value can't be fetched in time or fails
+func (c *CachedData[T]) SetFallback(fallback func() (T, bool)) {
+	if c.state == nil {
+		return
+	}
+	c.state.mutex.Lock()
+	defer c.state.mutex.Unlock()
+	c.state.fallback = fallback
+}
+
+// Returns how long to wait for the value, and whether the wait is limited at all. Must be called with the mutex held
+func (s *cachedDataState[T]) getWaitDuration() (time.Duration, bool) {
+	var wait time.Duration
+	isLimited := false
+	if s.timeout > 0 {
+		wait = time.Until(s.started.Add(s.timeout))
+		isLimited = true
+	}
+	if !s.deadline.IsZero() {
+		untilDeadline := time.Until(s.deadline)
+		if !isLimited || untilDeadline < wait {
+			wait = untilDeadline
+		}
+		isLimited = true
+	}
+	// If the time is already up, the value is still returned when it is ready
+	return max(wait, 0), isLimited
+}
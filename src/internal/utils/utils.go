@@ -2,6 +2,8 @@ package utils
 
 import (
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/rs/zerolog"
@@ -66,34 +68,50 @@ func callerFormatter(i interface{}) string {
 	return result
 }
 
-// Cached Data
+/*
+ * ---------------- String Utils ----------------
+ */
 
-type CachedData[T any] struct {
-	name           string
-	contentChannel chan T
-	content        T
-	isCached       bool
-	refresh        func(chan T)
-}
+// Matches CSI sequences (colors, cursor movement) and OSC sequences (titles, hyperlinks)
+var ansiRegexp = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(\x07|\x1b\\)`)
 
-func NewCachedData[T any](refresh func(chan T), name string) CachedData[T] {
-	newCachedData := CachedData[T]{
-		name:           name,
-		isCached:       false,
-		refresh:        refresh,
-		contentChannel: make(chan T),
-	}
+// StripANSI removes ANSI escape sequences from a string
+func StripANSI(str string) string {
+	return ansiRegexp.ReplaceAllString(str, "")
+}
 
-	go newCachedData.refresh(newCachedData.contentChannel)
+/*
+ * ---------------- File Utils ----------------
+ */
 
-	return newCachedData
+// GetCacheDir returns the promptorium cache directory ($XDG_CACHE_HOME/promptorium, or ~/.cache/promptorium)
+func GetCacheDir() string {
+	cacheDir := os.Getenv("XDG_CACHE_HOME")
+	if cacheDir == "" {
+		cacheDir = filepath.Join(os.Getenv("HOME"), ".cache")
+	}
+	return filepath.Join(cacheDir, "promptorium")
 }
 
-func (c *CachedData[T]) GetContent() T {
-	if !c.isCached {
-		c.content = <-c.contentChannel
-		c.isCached = true
+// WriteFileAtomic writes the file through a temporary file, so that readers never see it partially written.
+// The parent directories are created if needed.
+func WriteFileAtomic(path string, content []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
 	}
-
-	return c.content
+	tempFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tempFile.Write(content)
+	closeErr := tempFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempFile.Name())
+		return err
+	}
+	return os.Rename(tempFile.Name(), path)
 }
@@ -2,9 +2,8 @@ package main
 
 import (
 	"promptorium/cmd"
+	"promptorium/internal/log"
 	"promptorium/internal/utils"
-
-	"github.com/rs/zerolog/log"
 )
 
 var Version string
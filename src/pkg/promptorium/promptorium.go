@@ -0,0 +1,168 @@
+// Package promptorium renders promptorium prompts from other Go programs.
+//
+// Unlike the promptorium command, the package never probes the system for context and never
+// modifies global state: the caller supplies the context values, and modules registered on a
+// Renderer are only visible to that Renderer. Components of type command and plugin still run
+// their programs when the prompt is rendered. The internal logs are discarded, and the problems
+// found in the config or in the plugins are passed to the handler set with Renderer.SetProblemHandler
+// instead of being written to stderr.
+package promptorium
+
+import (
+	"os"
+	"path/filepath"
+	"promptorium/internal/pkg/confpkg/config"
+	"promptorium/internal/pkg/confpkg/context"
+	"promptorium/internal/pkg/confpkg/context/gitcontext"
+	"promptorium/internal/pkg/confpkg/context/oscontext"
+	"promptorium/internal/pkg/confpkg/context/vcscontext"
+	"promptorium/internal/pkg/promptpkg"
+	"time"
+)
+
+type (
+	// Config is a parsed promptorium configuration, bound to the context it was built with
+	Config = config.Config
+	// Module is a module that can be used by components of type "module"
+	Module = config.ModuleEntry
+	// ModuleOption describes an option accepted by a module
+	ModuleOption = config.ModuleOption
+	// Component is a component of the configuration, as passed to Module.Get
+	Component = config.Component
+	// ComponentContent is a piece of content returned by Module.Get
+	ComponentContent = config.ComponentContent
+	// Segment is a piece of rendered prompt text together with its style
+	Segment = promptpkg.Segment
+	// GitContext holds the state of the git repository
+	GitContext = gitcontext.GitContext
+	// VCSContext holds the state of the repository, whatever its version control system
+	VCSContext = vcscontext.VCSContext
+	// OS is the operating system, as used by the os_icon and os modules
+	OS = oscontext.OS
+)
+
+// NewComponentContent returns a ComponentContent styled like the component, for use in Module.Get
+func NewComponentContent(component *Component, str string, len int) ComponentContent {
+	return config.NewComponentContent(component, str, len)
+}
+
+// Context holds the values the prompt is rendered with.
+// If Time is not set, the current time is used. If HomeDir is not set, the paths are displayed without "~".
+type Context struct {
+	ExitCode      int
+	PipeStatus    []int
+	CmdDuration   time.Duration
+	Jobs          int
+	CWD           string
+	Git           GitContext
+	GitRoot       string
+	VCS           VCSContext
+	OS            OS
+	Shell         string
+	TerminalWidth int
+	User          string
+	Hostname      string
+	HomeDir       string
+	Time          time.Time
+}
+
+// Renderer builds configs and renders prompts with a set of modules
+type Renderer struct {
+	modules        config.ModuleRegistry
+	version        string
+	problemHandler func(error)
+}
+
+// NewRenderer returns a Renderer with the built-in modules
+func NewRenderer() *Renderer {
+	return &Renderer{
+		modules: config.GetModuleRegistry(),
+	}
+}
+
+// SetVersion sets the version reported to plugins
+func (r *Renderer) SetVersion(version string) {
+	r.version = version
+}
+
+// SetProblemHandler sets the function receiving the problems which don't stop the rendering, like an
+// invalid color replaced by its default or a failing plugin. Without a handler, they are discarded.
+func (r *Renderer) SetProblemHandler(handler func(error)) {
+	r.problemHandler = handler
+}
+
+// RegisterModule adds a module to the renderer, replacing any module with the same name
+func (r *Renderer) RegisterModule(module Module) {
+	r.modules.Register(module)
+}
+
+// Modules returns the modules available to the renderer, sorted by name
+func (r *Renderer) Modules() []Module {
+	return r.modules.List()
+}
+
+// ConfigFromFile reads and parses the config file at path.
+// If path is empty, the default config file (~/.config/promptorium/config.yaml) is used.
+// An error is returned if the file can't be read or parsed.
+func (r *Renderer) ConfigFromFile(path string, ctx Context) (Config, error) {
+	data, path, err := config.ReadConfigFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	rawConfig := config.GetRawConfigFromBytes(data, filepath.Dir(path), r.getApplicationContext(ctx), r.version)
+	return config.ParseConfigWithModules(rawConfig, r.modules)
+}
+
+// ConfigFromBytes parses the content of a config file.
+// Relative paths to components, theme, options and prompt files are resolved from baseDir.
+// An error is returned if data can't be parsed.
+func (r *Renderer) ConfigFromBytes(data []byte, baseDir string, ctx Context) (Config, error) {
+	err := config.CheckConfigFile(data)
+	if err != nil {
+		return Config{}, err
+	}
+	if baseDir == "" {
+		baseDir, _ = os.Getwd()
+	}
+	rawConfig := config.GetRawConfigFromBytes(data, filepath.Clean(baseDir), r.getApplicationContext(ctx), r.version)
+	return config.ParseConfigWithModules(rawConfig, r.modules)
+}
+
+// Render returns the prompt string for the config
+func Render(conf Config) string {
+	return promptpkg.NewPromptBuilder(conf).BuildPrompt().Render()
+}
+
+// Segments returns the prompt for the config as a list of styled segments
+func Segments(conf Config) []Segment {
+	return promptpkg.NewPromptBuilder(conf).BuildPrompt().Segments()
+}
+
+func (r *Renderer) getApplicationContext(ctx Context) *context.ApplicationContext {
+	if ctx.Time.IsZero() {
+		ctx.Time = time.Now()
+	}
+	appContext := context.NewApplicationContextFromSnapshot(context.Snapshot{
+		ExitCode:      ctx.ExitCode,
+		PipeStatus:    ctx.PipeStatus,
+		CmdDuration:   ctx.CmdDuration,
+		Jobs:          ctx.Jobs,
+		CWD:           ctx.CWD,
+		GitContext:    ctx.Git,
+		GitRoot:       ctx.GitRoot,
+		VCSContext:    ctx.VCS,
+		OS:            ctx.OS,
+		Shell:         context.GetShellType(ctx.Shell),
+		TerminalWidth: ctx.TerminalWidth,
+		User:          ctx.User,
+		Hostname:      ctx.Hostname,
+		HomeDir:       ctx.HomeDir,
+		Time:          ctx.Time,
+	})
+	handler := r.problemHandler
+	if handler == nil {
+		handler = func(error) {}
+	}
+	appContext.SetProblemHandler(handler)
+	return appContext
+}